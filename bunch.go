@@ -1,55 +1,106 @@
 package main
 
 import (
-	"fmt"
+	"log"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path"
-	"time"
+	"syscall"
 
 	"github.com/codegangsta/cli"
+	"github.com/dkulchenko/bunch/pkg/bunch"
+	"github.com/fatih/color"
 	"github.com/kardianos/osext"
 )
 
-var InitialPath string
-var InitialGoPath string
+var project *bunch.Project
 
-var Verbose bool
+var JSONOutput bool
 
-var SpinnerCharSet = 14
-var SpinnerInterval = 50 * time.Millisecond
+// runVendoredBunch hands args to the bunch installed in the vendor directory if the Bunchfile
+// asks for it with !bunch vendored, and returns its exit status. ran is false if there is
+// nothing to hand over to, or the vendored bunch can't be run at all.
+func runVendoredBunch(args []string) (status int, ran bool) {
+	if !project.HasBunchfile() {
+		return 0, false
+	}
+
+	b, err := project.Bunchfile()
+	if err != nil || !b.VendoredBunch {
+		return 0, false // a broken Bunchfile is reported by the command itself
+	}
 
-func main() {
 	currentExecutable, _ := osext.Executable()
-	vendoredBunchPath := path.Join(".vendor", "bin", "bunch")
+	vendoredBunchPath := path.Join(project.VendorDir, "bin", "bunch")
 
 	fi1, errStat1 := os.Stat(currentExecutable)
 	fi2, errStat2 := os.Stat(vendoredBunchPath)
 
-	if exists, _ := pathExists(vendoredBunchPath); errStat1 == nil && errStat2 == nil && exists && !os.SameFile(fi1, fi2) {
-		cmd := exec.Command(vendoredBunchPath, os.Args[1:]...)
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+	if errStat1 == nil && errStat2 == nil && os.SameFile(fi1, fi2) {
+		return 0, false // this is the vendored bunch
+	}
+
+	if errStat2 != nil {
+		log.Printf("Bunchfile asks for the vendored bunch, but %s isn't installed, using %s", vendoredBunchPath, currentExecutable)
+		return 0, false
+	}
+
+	cmd := exec.Command(vendoredBunchPath, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Start()
+	if err != nil {
+		log.Printf("unable to run vendored bunch (%s), using %s", err, currentExecutable)
+		return 0, false
+	}
 
-		err := cmd.Run()
-		if err == nil {
-			if cmd.ProcessState.Success() {
-				os.Exit(0)
+	// Ctrl-C reaches the vendored bunch too, so wait for it to clean up; SIGTERM is passed on
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGTERM {
+				_ = cmd.Process.Signal(sig)
 			}
 		}
+	}()
+
+	_ = cmd.Wait()
+	signal.Stop(signals)
+
+	return exitStatus(cmd.ProcessState), true
+}
+
+func exitStatus(state *os.ProcessState) int { // as a shell reports it, 128+n for processes killed by signal n
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return state.ExitCode()
+}
+
+func main() {
+	wd, err := os.Getwd()
+	if err != nil {
+		log.Fatalf("unable to determine project root: %s", err)
+	}
 
-		// if "subbunch" succeeded, exit, otherwise, continue with regular bunch business
-		fmt.Println("vendored bunch exited with a non-zero exit status, trying again with global bunch")
+	project, err = bunch.Open(wd)
+	if err != nil {
+		fatal(err, "unable to open project")
 	}
 
-	InitialPath = os.Getenv("PATH")
-	InitialGoPath = os.Getenv("GOPATH")
+	if status, ran := runVendoredBunch(os.Args[1:]); ran {
+		os.Exit(status)
+	}
 
 	app := cli.NewApp()
 	app.Name = "bunch"
 	app.Usage = "npm-like tool for managing Go dependencies"
-	app.Version = "0.6"
+	app.Version = "0.7"
 	app.Authors = []cli.Author{cli.Author{Name: "Daniil Kulchenko", Email: "daniil@kulchenko.com"}}
 
 	app.Flags = []cli.Flag{
@@ -57,10 +108,37 @@ func main() {
 			Name:  "verbose",
 			Usage: "output more information",
 		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "show what install, update, uninstall and prune would do without changing anything",
+		},
+		cli.DurationFlag{
+			Name:  "lock-timeout",
+			Usage: "how long to wait for another bunch process to release the vendor lock (e.g. 30s)",
+		},
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "output machine-readable JSON (outdated, ls, install, update)",
+		},
 	}
 
 	app.Before = func(context *cli.Context) error {
-		Verbose = context.GlobalBool("verbose")
+		JSONOutput = context.GlobalBool("json")
+
+		project.Verbose = context.GlobalBool("verbose")
+		project.Quiet = JSONOutput
+		project.DryRun = context.GlobalBool("dry-run")
+
+		if context.GlobalIsSet("lock-timeout") {
+			project.LockTimeout = context.GlobalDuration("lock-timeout")
+		}
+
+		switch project.Config.Get("color") {
+		case "always":
+			color.NoColor = false
+		case "never":
+			color.NoColor = true
+		}
 
 		return nil
 	}
@@ -79,6 +157,10 @@ func main() {
 					Name:  "g",
 					Usage: "install package to global $GOPATH instead of vendored directory",
 				},
+				cli.StringFlag{
+					Name:  "target",
+					Usage: "also build packages for these comma-separated GOOS/GOARCH pairs, e.g. linux/arm64,darwin/amd64",
+				},
 			},
 			Action: func(c *cli.Context) error {
 				installCommand(c, false, true, true)
@@ -89,6 +171,16 @@ func main() {
 			Name:    "update",
 			Aliases: []string{"u"},
 			Usage:   "update package(s)",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "show the changes each package would receive without installing them (same as the global --dry-run)",
+				},
+				cli.StringFlag{
+					Name:  "target",
+					Usage: "also build packages for these comma-separated GOOS/GOARCH pairs, e.g. linux/arm64,darwin/amd64",
+				},
+			},
 			Action: func(c *cli.Context) error {
 				installCommand(c, true, true, false)
 				return nil
@@ -121,6 +213,28 @@ func main() {
 				return nil
 			},
 		},
+		{
+			Name:  "upgrade",
+			Usage: "raise Bunchfile version constraints to newer releases",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "latest",
+					Usage: "upgrade to the latest release, including new major versions",
+				},
+				cli.BoolFlag{
+					Name:  "minor",
+					Usage: "upgrade to the latest release with the same major version",
+				},
+				cli.BoolFlag{
+					Name:  "patch",
+					Usage: "upgrade to the latest release with the same major and minor version",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				upgradeCommand(c)
+				return nil
+			},
+		},
 		{
 			Name:  "outdated",
 			Usage: "list outdated packages",
@@ -129,6 +243,24 @@ func main() {
 				return nil
 			},
 		},
+		{
+			Name:    "ls",
+			Aliases: []string{"list"},
+			Usage:   "list packages in Bunchfile and their installed versions",
+			Action: func(c *cli.Context) error {
+				lsCommand(c)
+				return nil
+			},
+		},
+		{
+			Name:      "diff",
+			Usage:     "show commits and changed files between the installed and target revision of a package",
+			ArgsUsage: "<package> [from] [to]",
+			Action: func(c *cli.Context) error {
+				diffCommand(c)
+				return nil
+			},
+		},
 		{
 			Name:  "lock",
 			Usage: "generate a file locking down current versions of dependencies",
@@ -153,6 +285,58 @@ func main() {
 				return nil
 			},
 		},
+		{
+			Name:  "logs",
+			Usage: "show the output of the commands run by the last bunch run",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "list",
+					Usage: "list the logs of recent runs instead",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				logsCommand(c)
+				return nil
+			},
+		},
+		{
+			Name:  "config",
+			Usage: "show or change bunch settings (.bunchrc and ~/.bunch/config)",
+			Subcommands: []cli.Command{
+				{
+					Name:      "get",
+					Usage:     "print the effective value of a setting",
+					ArgsUsage: "<key>",
+					Action: func(c *cli.Context) error {
+						configGetCommand(c)
+						return nil
+					},
+				},
+				{
+					Name:      "set",
+					Usage:     "change a setting in the project's .bunchrc (or ~/.bunch/config with --global)",
+					ArgsUsage: "<key> <value>",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "global",
+							Usage: "write to ~/.bunch/config instead of the project's .bunchrc",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						configSetCommand(c)
+						return nil
+					},
+				},
+				{
+					Name:  "list",
+					Usage: "list all settings with their effective values and where they come from",
+					Action: func(c *cli.Context) error {
+						configListCommand(c)
+						return nil
+					},
+				},
+			},
+		},
 		{
 			Name:            "go",
 			Usage:           "run a Go command within the vendor environment (e.g. bunch go fmt)",
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/dkulchenko/bunch/pkg/bunch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunVendoredBunchExitStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bunch-vendored")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	_ = ioutil.WriteFile(path.Join(dir, "Bunchfile"), []byte("!bunch vendored\ngithub.com/dkulchenko/bunch v0.7\n"), 0644)
+	_ = os.MkdirAll(path.Join(dir, ".vendor", "bin"), 0755)
+
+	runs := path.Join(dir, "runs")
+	script := "#!/bin/sh\necho \"$@\" >> " + runs + "\nexit 3\n"
+	_ = ioutil.WriteFile(path.Join(dir, ".vendor", "bin", "bunch"), []byte(script), 0755)
+
+	previousProject := project
+	defer func() { project = previousProject }()
+
+	project, err = bunch.Open(dir)
+	assert.Nil(t, err, "project should open")
+
+	status, ran := runVendoredBunch([]string{"install"})
+	assert.True(t, ran, "vendored bunch should be run")
+	assert.Equal(t, 3, status, "vendored bunch's exit status should be passed on")
+
+	output, err := ioutil.ReadFile(runs)
+	assert.Nil(t, err, "vendored bunch should have recorded its run")
+	assert.Equal(t, []string{"install"}, strings.Split(strings.TrimSpace(string(output)), "\n"), "vendored bunch should run exactly once with the command's arguments")
+}
+
+func TestRunVendoredBunchNotRequested(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bunch-vendored")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	_ = ioutil.WriteFile(path.Join(dir, "Bunchfile"), []byte("github.com/dkulchenko/bunch v0.7\n"), 0644)
+	_ = os.MkdirAll(path.Join(dir, ".vendor", "bin"), 0755)
+	_ = ioutil.WriteFile(path.Join(dir, ".vendor", "bin", "bunch"), []byte("#!/bin/sh\nexit 3\n"), 0755)
+
+	previousProject := project
+	defer func() { project = previousProject }()
+
+	project, err = bunch.Open(dir)
+	assert.Nil(t, err, "project should open")
+
+	_, ran := runVendoredBunch([]string{"install"})
+	assert.False(t, ran, "vendored bunch should be left alone without !bunch vendored")
+}
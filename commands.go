@@ -1,32 +1,103 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/codegangsta/cli"
+	"github.com/dkulchenko/bunch/pkg/bunch"
+	"github.com/fatih/color"
 	"github.com/juju/errors"
 )
 
-func setupVendoring() error {
-	vendorDirs := []string{".vendor/bin", ".vendor/pkg", ".vendor/src"}
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "    ")
+	encoder.SetEscapeHTML(false) // constraints like ">= 1.0" should stay readable
 
-	for _, vendorDir := range vendorDirs {
-		err := os.MkdirAll(vendorDir, 0755)
-
-		if err != nil {
-			return errors.Trace(err)
-		}
+	err := encoder.Encode(v)
+	if err != nil {
+		return errors.Trace(err)
 	}
 
 	return nil
 }
 
+// exit statuses for each kind of failure, documented in the README
+var exitCodes = map[bunch.ErrorKind]int{
+	bunch.KindUnknown:      1,
+	bunch.KindBunchfile:    2,
+	bunch.KindFetch:        3,
+	bunch.KindConstraint:   4,
+	bunch.KindBuild:        5,
+	bunch.KindLockMismatch: 6,
+	bunch.KindDirtyVendor:  7,
+	bunch.KindUsage:        8,
+	bunch.KindConfig:       9,
+}
+
+// exit status after Ctrl-C, as shells report for processes killed by SIGINT
+const interruptedExitCode = 130
+
+var interruptContext context.Context
+
+// interruptible returns a context that is cancelled on the first Ctrl-C (or SIGTERM), so the
+// running operation can kill its commands and restore the vendor tree. A second one quits
+// right away.
+func interruptible() context.Context {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-ctx.Done()
+		stop()
+
+		log.Printf("interrupted, cleaning up (press Ctrl-C again to quit right away)")
+	}()
+
+	interruptContext = ctx
+	return ctx
+}
+
+func fatal(err error, format string, args ...interface{}) {
+	log.Printf("%s: %s", fmt.Sprintf(format, args...), err)
+
+	if project != nil && project.Verbose {
+		fmt.Fprintf(os.Stderr, "\n%s\n", errors.ErrorStack(err))
+	}
+
+	if interruptContext != nil && interruptContext.Err() != nil {
+		os.Exit(interruptedExitCode)
+	}
+
+	os.Exit(exitCodes[bunch.KindOf(err)])
+}
+
+// failWith prints a failure found by the command itself, rather than returned by bunch, and exits
+// with the status of kind
+func failWith(kind bunch.ErrorKind, format string, args ...interface{}) {
+	log.Printf(format, args...)
+	os.Exit(exitCodes[kind])
+}
+
+func requireBunchfile(action string) {
+	if !project.HasBunchfile() {
+		failWith(bunch.KindBunchfile, "can't %s without Bunchfile", action)
+	}
+}
+
 func installCommand(c *cli.Context, forceUpdate bool, checkUpstream bool, respectLocked bool) {
 	// bunch install
 	// bunch install github.com/abc/xyz
@@ -34,209 +105,413 @@ func installCommand(c *cli.Context, forceUpdate bool, checkUpstream bool, respec
 	// bunch install github.com/abc/xyz --save
 	// bunch install github.com/abc/xyz -g
 	// bunch install abc/xyz # github shorthand
+	// bunch install --target linux/arm64,darwin/amd64
+	// bunch --dry-run install
 
 	// bunch update
 	// bunch update github.com/abc/xyz
 	// bunch update github.com/abc/xyz github.com/abc/def
 	// bunch update github.com/abc/xyz --save
 	// bunch update github.com/abc/xyz -g
+	// bunch update --dry-run
+
+	if c.Bool("dry-run") {
+		project.DryRun = true
+	}
 
 	packages := c.Args()
 
-	err := setupVendoring()
+	targets, err := bunch.ParseTargets(c.String("target"))
 	if err != nil {
-		log.Fatalf("unable to set up vendor dirs: %s", err)
+		fatal(err, "invalid --target")
 	}
 
 	if len(packages) == 0 {
-		bunch, err := readBunchfile()
-		if err != nil {
-			log.Fatalf("unable to read Bunchfile: %s", err)
-		}
+		requireBunchfile("install packages")
+	} else if c.Bool("g") && os.Getenv("GOPATH") == "" {
+		failWith(bunch.KindUsage, "GOPATH must be set when -g used")
+	}
 
-		err = installPackagesFromBunchfile(bunch, forceUpdate, checkUpstream, respectLocked)
+	result, err := project.Install(interruptible(), bunch.InstallOptions{
+		Packages:      packages,
+		ForceUpdate:   forceUpdate,
+		CheckUpstream: checkUpstream,
+		RespectLocked: respectLocked,
+		Global:        c.Bool("g"),
+		Save:          c.Bool("save"),
+		Targets:       targets,
+	})
+	if err != nil {
+		fatal(err, "failed installing packages")
+	}
 
-		if err != nil {
-			log.Fatalf("failed installing packages: %s %s", err, err.(*errors.Err).StackTrace())
-		}
-	} else {
-		global := c.Bool("g")
-		save := c.Bool("save")
+	if JSONOutput && result.Report != nil {
+		err = printJSON(result.Report)
+	} else if JSONOutput {
+		err = printJSON(result.Plan)
+	}
+	if err != nil {
+		fatal(err, "failed printing install report")
+	}
+}
 
-		if global && os.Getenv("GOPATH") == "" {
-			log.Fatalf("GOPATH must be set when -g used")
-		}
+func uninstallCommand(c *cli.Context) {
+	// bunch uninstall github.com/abc/xyz
+	// bunch uninstall github.com/abc/xyz --save
+	// bunch uninstall github.com/abc/xyz -g
+	// bunch --dry-run uninstall github.com/abc/xyz
 
-		var bunch *BunchFile
-		if exists, _ := pathExists("Bunchfile"); exists {
-			bunch, err = readBunchfile()
-			if err != nil {
-				log.Fatalf("unable to read Bunchfile: %s", err)
-			}
-		} else {
-			bunch = createBunchfile()
-		}
+	packages := c.Args()
 
-		err := installPackagesFromRepoStrings(packages, global, forceUpdate, checkUpstream, respectLocked)
-		if err != nil {
-			log.Fatalf("failed installing packages: %s", err)
+	if len(packages) == 0 {
+		failWith(bunch.KindUsage, "uninstall requires an argument")
+	}
+
+	if c.Bool("g") && os.Getenv("GOPATH") == "" {
+		failWith(bunch.KindUsage, "GOPATH must be set when -g used")
+	}
+
+	err := project.Uninstall(interruptible(), packages, bunch.UninstallOptions{Global: c.Bool("g"), Save: c.Bool("save")})
+	if err != nil {
+		fatal(err, "failed removing packages")
+	}
+}
+
+func pruneCommand(c *cli.Context) {
+	// bunch prune
+	// bunch --dry-run prune
+
+	requireBunchfile("prune")
+
+	err := project.Prune(interruptible())
+	if err != nil {
+		fatal(err, "failed pruning packages")
+	}
+}
+
+func upgradeCommand(c *cli.Context) {
+	// bunch upgrade
+	// bunch upgrade github.com/abc/xyz
+	// bunch upgrade --latest
+	// bunch upgrade --minor
+	// bunch upgrade --patch github.com/abc/xyz
+
+	mode := bunch.UpgradeInteractive
+	modeFlags := 0
+
+	if c.Bool("latest") {
+		mode = bunch.UpgradeLatest
+		modeFlags++
+	}
+	if c.Bool("minor") {
+		mode = bunch.UpgradeMinor
+		modeFlags++
+	}
+	if c.Bool("patch") {
+		mode = bunch.UpgradePatch
+		modeFlags++
+	}
+
+	if modeFlags > 1 {
+		failWith(bunch.KindUsage, "only one of --latest, --minor and --patch may be used")
+	}
+
+	requireBunchfile("upgrade packages")
+
+	err := project.Upgrade(interruptible(), c.Args(), bunch.UpgradeOptions{Mode: mode, Choose: promptUpgrade(bufio.NewReader(os.Stdin))})
+	if err != nil {
+		fatal(err, "failed upgrading packages")
+	}
+}
+
+// promptUpgrade asks on stdin which tag to upgrade each package to, by number or name
+func promptUpgrade(reader *bufio.Reader) bunch.UpgradeChooser {
+	return func(repo string, tags []string) (string, error) {
+		for i, tag := range tags {
+			fmt.Printf("  %d) %s\n", i+1, tag)
 		}
 
-		if save {
-			for _, pack := range packages {
-				err := bunch.AddPackage(pack)
+		for {
+			fmt.Printf("  upgrade %s to [1-%d, enter to skip]: ", repo, len(tags))
+
+			answer, err := reader.ReadString('\n')
+			answer = strings.TrimSpace(answer)
 
-				if err != nil {
-					log.Fatalf("failed adding package %s to save list: %s", pack, err)
+			if answer == "" {
+				return "", nil
+			}
+
+			if choice, convErr := strconv.Atoi(answer); convErr == nil && choice >= 1 && choice <= len(tags) {
+				return tags[choice-1], nil
+			}
+
+			for _, tag := range tags {
+				if tag == answer {
+					return tag, nil
 				}
 			}
 
-			err = bunch.Save()
 			if err != nil {
-				log.Fatalf("failed saving Bunchfile: %s", err)
+				return "", errors.Trace(err)
 			}
+
+			fmt.Println(color.RedString("  invalid choice %q", answer))
 		}
 	}
 }
 
-func uninstallCommand(c *cli.Context) {
-	// bunch uninstall github.com/abc/xyz
-	// bunch uninstall github.com/abc/xyz --save
-	// bunch uninstall github.com/abc/xyz -g
+func outdatedCommand(c *cli.Context) {
+	// bunch outdated
+	// bunch --json outdated
 
-	packages := c.Args()
+	requireBunchfile("check for outdated packages")
 
-	err := setupVendoring()
+	report, err := project.Outdated(interruptible())
 	if err != nil {
-		log.Fatalf("unable to set up vendor dirs: %s", err)
+		fatal(err, "failed checking for outdated packages")
 	}
 
-	if len(packages) == 0 {
-		log.Fatalf("uninstall requires an argument")
-	} else {
-		global := c.Bool("g")
-		save := c.Bool("save")
+	if JSONOutput {
+		err = printJSON(report)
+		if err != nil {
+			fatal(err, "failed printing outdated packages")
+		}
+		return
+	}
 
-		if global && os.Getenv("GOPATH") == "" {
-			log.Fatalf("GOPATH must be set when -g used")
+	for _, outdated := range report {
+		var status string
+		switch outdated.Status {
+		case "up to date":
+			status = color.GreenString(outdated.Status)
+		case "outdated":
+			status = color.RedString(outdated.Status)
+		default:
+			status = color.YellowString(outdated.Status)
 		}
 
-		var bunch *BunchFile
-		if exists, _ := pathExists("Bunchfile"); exists {
-			bunch, err = readBunchfile()
-			if err != nil {
-				log.Fatalf("unable to read Bunchfile: %s", err)
-			}
+		if outdated.CommitsBehind == 0 {
+			fmt.Printf("package %s ... %s%s\n", outdated.Repo, status, tagSummary(outdated.PackageTagInfo))
 		} else {
-			bunch = createBunchfile()
+			fmt.Printf("package %s ... %s by %s, current is %6s, latest is %6s%s\n", outdated.Repo, status, bunch.CountCommits(outdated.CommitsBehind), bunch.ShortCommit(outdated.InstalledCommit), bunch.ShortCommit(outdated.TargetCommit), tagSummary(outdated.PackageTagInfo))
 		}
+	}
+}
+
+func tagSummary(tagInfo bunch.PackageTagInfo) string {
+	if tagInfo.LatestTag == "" {
+		return ""
+	}
 
-		err := removePackages(packages, bunch, global)
+	summary := fmt.Sprintf(" (%s)", tagInfo)
+
+	if tagInfo.WantedTag != "" && tagInfo.WantedTag != tagInfo.LatestTag {
+		return color.YellowString(summary)
+	}
+
+	return summary
+}
+
+func lsCommand(c *cli.Context) {
+	// bunch ls
+	// bunch --json ls
+
+	requireBunchfile("list packages")
+
+	report, err := project.List(interruptible())
+	if err != nil {
+		fatal(err, "failed listing packages")
+	}
+
+	if JSONOutput {
+		err = printJSON(report)
 		if err != nil {
-			log.Fatalf("failed removing packages: %s", err)
+			fatal(err, "failed printing packages")
 		}
+		return
+	}
 
-		if save {
-			for _, pack := range packages {
-				err := bunch.RemovePackage(pack)
+	for _, listed := range report {
+		var state string
 
-				if err != nil {
-					log.Fatalf("failed removing package %s from save list: %s", pack, err)
-				}
-			}
+		if listed.IsLink {
+			state = color.CyanString("-> %s", listed.LinkTarget)
+		} else if !listed.Installed {
+			state = color.RedString("not installed")
+		} else if listed.LockedVersion != "" {
+			state = color.GreenString("%s (locked)", bunch.ShortCommit(listed.InstalledCommit))
+		} else {
+			state = color.GreenString(bunch.ShortCommit(listed.InstalledCommit))
+		}
 
-			err = bunch.Save()
-			if err != nil {
-				log.Fatalf("failed saving Bunchfile: %s", err)
-			}
+		if listed.Version != "" && !listed.IsLink {
+			fmt.Printf("%s %s ... %s\n", listed.Repo, listed.Version, state)
+		} else {
+			fmt.Printf("%s ... %s\n", listed.Repo, state)
 		}
 	}
 }
 
-func pruneCommand(c *cli.Context) {
-	// bunch prune
+func diffCommand(c *cli.Context) {
+	// bunch diff github.com/abc/xyz
+	// bunch diff github.com/abc/xyz v1.2.0
+	// bunch diff github.com/abc/xyz v1.2.0 v1.3.0
+	// bunch --json diff github.com/abc/xyz
+
+	args := c.Args()
+
+	if len(args) < 1 || len(args) > 3 {
+		failWith(bunch.KindUsage, "usage: bunch diff <package> [from] [to]")
+	}
 
-	err := setupVendoring()
+	var fromRev, toRev string
+	if len(args) >= 2 {
+		fromRev = args[1]
+	}
+	if len(args) == 3 {
+		toRev = args[2]
+	}
+
+	diff, err := project.Diff(interruptible(), args[0], fromRev, toRev)
 	if err != nil {
-		log.Fatalf("unable to set up vendor dirs: %s", err)
+		fatal(err, "failed diffing package %s", args[0])
 	}
 
-	var bunch *BunchFile
-	if exists, _ := pathExists("Bunchfile"); exists {
-		bunch, err = readBunchfile()
+	if JSONOutput {
+		err = printJSON(diff)
 		if err != nil {
-			log.Fatalf("unable to read Bunchfile: %s", err)
+			fatal(err, "failed printing diff")
 		}
-	} else {
-		log.Fatalf("can't prune without Bunchfile")
+		return
 	}
 
-	err = prunePackages(bunch)
-	if err != nil {
-		log.Fatalf("failed pruning packages: %s", err)
+	fmt.Printf("package %s: %s (%s) -> %s (%s)\n", diff.Repo, diff.From, bunch.ShortCommit(diff.FromCommit), diff.To, bunch.ShortCommit(diff.ToCommit))
+
+	if diff.FromCommit == diff.ToCommit {
+		fmt.Println(color.GreenString("no changes"))
+		return
+	}
+
+	if len(diff.Commits) > 0 {
+		fmt.Printf("\n%s:\n", bunch.CountCommits(len(diff.Commits)))
+		for _, line := range diff.Commits {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+
+	if len(diff.RolledBack) > 0 {
+		fmt.Printf("\n%s %s:\n", bunch.CountCommits(len(diff.RolledBack)), color.YellowString("would be rolled back"))
+		for _, line := range diff.RolledBack {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+
+	if len(diff.Diffstat) > 0 {
+		fmt.Println("")
+		for _, line := range diff.Diffstat {
+			fmt.Println(line)
+		}
 	}
 }
 
-func outdatedCommand(c *cli.Context) {
-	// bunch outdated
+func logsCommand(c *cli.Context) {
+	// bunch logs
+	// bunch logs --list
 
-	err := setupVendoring()
+	logs, err := project.Logs()
 	if err != nil {
-		log.Fatalf("unable to set up vendor dirs: %s", err)
+		fatal(err, "failed reading logs")
 	}
 
-	var bunch *BunchFile
-	if exists, _ := pathExists("Bunchfile"); exists {
-		bunch, err = readBunchfile()
-		if err != nil {
-			log.Fatalf("unable to read Bunchfile: %s", err)
+	if c.Bool("list") {
+		for _, logPath := range logs {
+			fmt.Println(logPath)
 		}
-	} else {
-		log.Fatalf("can't check for outdated packages without Bunchfile")
+		return
+	}
+
+	if len(logs) == 0 {
+		log.Fatalf("no logs found in %s", path.Join(project.VendorDir, ".bunch", "logs"))
 	}
 
-	err = checkOutdatedPackages(bunch)
+	file, err := os.Open(logs[len(logs)-1])
 	if err != nil {
-		log.Fatalf("failed checking for outdated packages: %s %s", err, err.(*errors.Err).StackTrace())
+		fatal(err, "failed reading log")
+	}
+	defer file.Close()
+
+	_, err = io.Copy(os.Stdout, file)
+	if err != nil {
+		fatal(err, "failed reading log")
 	}
 }
 
 func lockCommand(c *cli.Context) {
 	// bunch lock
 
-	err := setupVendoring()
+	requireBunchfile("lock packages")
+
+	err := project.Lock(interruptible())
 	if err != nil {
-		log.Fatalf("unable to set up vendor dirs: %s", err)
+		fatal(err, "failed locking packages")
 	}
+}
 
-	var bunch *BunchFile
-	if exists, _ := pathExists("Bunchfile"); exists {
-		bunch, err = readBunchfile()
-		if err != nil {
-			log.Fatalf("unable to read Bunchfile: %s", err)
-		}
-	} else {
-		log.Fatalf("can't lock packages without Bunchfile")
-	}
+func generateCommand(c *cli.Context) {
+	// bunch generate
 
-	err = lockPackages(bunch)
+	err := project.Generate(interruptible())
 	if err != nil {
-		log.Fatalf("failed locking packages: %s", err)
+		fatal(err, "failed generating Bunchfile")
+	}
+}
+
+func configGetCommand(c *cli.Context) {
+	// bunch config get vendor_dir
+
+	if len(c.Args()) != 1 {
+		failWith(bunch.KindUsage, "usage: bunch config get <key>")
+	}
+
+	name := c.Args()[0]
+	if _, known := bunch.FindConfigKey(name); !known {
+		failWith(bunch.KindConfig, "unknown setting %q", name)
 	}
 
+	fmt.Println(project.Config.Get(name))
 }
 
-func generateCommand(c *cli.Context) {
-	// bunch generate
+func configSetCommand(c *cli.Context) {
+	// bunch config set parallelism 4
+	// bunch config set --global default_host git.example.com
 
-	err := setupVendoring()
-	if err != nil {
-		log.Fatalf("unable to set up vendor dirs: %s", err)
+	if len(c.Args()) != 2 {
+		failWith(bunch.KindUsage, "usage: bunch config set [--global] <key> <value>")
+	}
+
+	configPath := bunch.ProjectConfigPath(project.Root)
+	if c.Bool("global") {
+		configPath = bunch.UserConfigPath()
 	}
 
-	err = generateBunchfile()
+	err := bunch.SetConfigValue(configPath, c.Args()[0], c.Args()[1])
 	if err != nil {
-		log.Fatalf("failed checking for outdated packages: %s", err)
+		fatal(err, "failed changing setting")
+	}
+}
+
+func configListCommand(c *cli.Context) {
+	// bunch config list
+
+	names := []string{}
+	for _, key := range bunch.ConfigKeys {
+		names = append(names, key.Name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value, source := project.Config.Lookup(name)
+		fmt.Printf("%s = %s (%s)\n", name, value, source)
 	}
 }
 
@@ -245,12 +520,12 @@ func goCommand(c *cli.Context) {
 	// bunch go fmt
 	// bunch go ...
 
-	err := setVendorEnv()
+	env, err := project.GoEnv()
 	if err != nil {
-		log.Fatalf("unable to set vendor env: %s", err)
+		fatal(err, "failed setting up environment")
 	}
 
-	cmd := exec.Command("go", c.Args()...)
+	cmd := commandWithEnv(env, "go", c.Args()...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -261,15 +536,28 @@ func goCommand(c *cli.Context) {
 	}
 }
 
+// commandWithEnv is exec.Command with env, looking name up on env's PATH rather than ours so the
+// project's Go version is found
+func commandWithEnv(env []string, name string, args ...string) *exec.Cmd {
+	resolved, err := bunch.LookPath(name, env)
+
+	cmd := exec.Command(name, args...)
+	cmd.Path = resolved
+	cmd.Err = err
+	cmd.Env = env
+
+	return cmd
+}
+
 func execCommand(c *cli.Context) {
 	// bunch exec make
 
-	err := setVendorEnv()
+	env, err := project.VendorEnv()
 	if err != nil {
-		log.Fatalf("unable to set vendor env: %s", err)
+		fatal(err, "failed setting up environment")
 	}
 
-	cmd := exec.Command(c.Args()[0], c.Args()[1:]...)
+	cmd := commandWithEnv(env, c.Args()[0], c.Args()[1:]...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -289,14 +577,14 @@ func shellCommand(c *cli.Context) {
 		shell = envShell
 	}
 
-	err := setVendorEnv()
+	env, err := project.VendorEnv()
 	if err != nil {
-		log.Fatalf("unable to set vendor env: %s", err)
+		fatal(err, "failed setting up environment")
 	}
 
 	fmt.Printf("starting bunch shell (%s)\n", shell)
 
-	cmd := exec.Command(shell)
+	cmd := commandWithEnv(env, shell)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -318,11 +606,27 @@ if [[ -n $(echo "$PATH" | grep .bunch/shims) ]]; then
   PATH=/usr/local/bin:/usr/local/sbin:/usr/sbin:/usr/bin:/bin
 fi
 
-if [[ -f "Bunchfile" && -d ".vendor" ]]; then
-  WD=$(pwd)
-  PATH="$WD/.vendor/bin:$PATH" GOPATH="$WD/.vendor/" go $@
+# find the project root by walking up to the nearest Bunchfile
+ROOT=$(pwd)
+while [[ "$ROOT" != "/" && ! -f "$ROOT/Bunchfile" ]]; do
+  ROOT=$(dirname "$ROOT")
+done
+
+VENDOR="${BUNCH_VENDOR_DIR:-.vendor}"
+if [[ -f "$ROOT/Bunchfile" ]] && command -v bunch > /dev/null; then
+  # vendor_dir may also be set in .bunchrc or ~/.bunch/config, so ask bunch for the effective value
+  VENDOR=$(cd "$ROOT" && bunch config get vendor_dir) || exit $?
+fi
+if [[ "$VENDOR" != /* ]]; then
+  VENDOR="$ROOT/$VENDOR"
+fi
+
+if [[ -f "$ROOT/Bunchfile" ]] && grep -q '^[[:space:]]*!go[[:space:]]' "$ROOT/Bunchfile" && command -v bunch > /dev/null; then
+  exec bunch go "$@" # picks the Go version the project asks for
+elif [[ -f "$ROOT/Bunchfile" && -d "$VENDOR" ]]; then
+  PATH="$VENDOR/bin:$PATH" GOPATH="$VENDOR" exec go "$@"
 else
-  go $@
+  exec go "$@"
 fi
 `
 
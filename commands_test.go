@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShimScriptUsesConfiguredVendorDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bunch-shim")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	dir, _ = filepath.EvalSymlinks(dir) // pwd in the shim reports the real path
+	root, bin := path.Join(dir, "project"), path.Join(dir, "bin")
+
+	_ = os.MkdirAll(path.Join(root, "sub"), 0755)
+	_ = os.MkdirAll(path.Join(root, "deps"), 0755)
+	_ = os.MkdirAll(bin, 0755)
+	_ = ioutil.WriteFile(path.Join(root, "Bunchfile"), []byte("github.com/a/b\n"), 0644)
+
+	// bunch reports vendor_dir as set in .bunchrc, from the project root; go reports what it was run with
+	fakeBunch := fmt.Sprintf("#!/bin/sh\n[ \"$*\" = \"config get vendor_dir\" ] && [ \"$(pwd)\" = %q ] && echo deps\n", root)
+	_ = ioutil.WriteFile(path.Join(bin, "bunch"), []byte(fakeBunch), 0755)
+	_ = ioutil.WriteFile(path.Join(bin, "go"), []byte("#!/bin/sh\necho \"$GOPATH\" \"$@\"\n"), 0755)
+
+	shim := path.Join(dir, "shim")
+	_ = ioutil.WriteFile(shim, []byte(shimScript), 0755)
+
+	cmd := exec.Command("bash", shim, "build", "./...")
+	cmd.Dir = path.Join(root, "sub")
+	cmd.Env = append(os.Environ(), "PATH="+bin+":/usr/bin:/bin", "BUNCH_VENDOR_DIR=")
+
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, "shim should run go: %s", output)
+	assert.Equal(t, path.Join(root, "deps")+" build ./...", strings.TrimSpace(string(output)), "go should get the vendor dir bunch reports as GOPATH")
+}
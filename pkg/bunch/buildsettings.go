@@ -0,0 +1,184 @@
+package bunch
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// BuildSettings are passed to go when building and installing a package. They come from !tags,
+// !ldflags and !env directives in the Bunchfile, either after a package or on a line of their
+// own for every package.
+type BuildSettings struct {
+	Tags    []string `json:"tags,omitempty"`
+	LDFlags []string `json:"ldflags,omitempty"`
+	Env     []string `json:"env,omitempty"` // NAME=value
+}
+
+// a directive after a package's version; values with spaces are written in double quotes
+var buildDirectiveRegexp = regexp.MustCompile(`\s*!(tags|ldflags|env):("[^"]*"|\S+)`)
+
+func splitList(value string) []string { // tags can be separated by commas or spaces, as go accepts them
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		return value[1 : len(value)-1]
+	}
+
+	return value
+}
+
+func quoteIfNeeded(value string) string {
+	if strings.IndexFunc(value, unicode.IsSpace) >= 0 {
+		return `"` + value + `"`
+	}
+
+	return value
+}
+
+// add records the value of a !tags, !ldflags or !env directive. Tags are a list, but ldflags are
+// kept as written, since commas and spaces are part of flags like -extldflags=-Wl,-z,relro, and
+// each !env sets one variable.
+func (s *BuildSettings) add(directive string, value string) error {
+	value = strings.TrimSpace(unquote(strings.TrimSpace(value)))
+	if value == "" {
+		return fmt.Errorf("!%s needs a value", directive)
+	}
+
+	switch directive {
+	case "tags":
+		s.Tags = append(s.Tags, splitList(value)...)
+	case "ldflags":
+		s.LDFlags = append(s.LDFlags, value)
+	case "env":
+		if !strings.Contains(value, "=") || strings.HasPrefix(value, "=") {
+			return fmt.Errorf("!env expects NAME=value, got %q", value)
+		}
+		s.Env = append(s.Env, value)
+	default:
+		return fmt.Errorf("unknown directive !%s", directive)
+	}
+
+	return nil
+}
+
+func parseBuildDirectives(version string) (string, BuildSettings, error) { // returns version with directives removed
+	settings := BuildSettings{}
+
+	for _, match := range buildDirectiveRegexp.FindAllStringSubmatch(version, -1) {
+		err := settings.add(match[1], match[2])
+		if err != nil {
+			return version, settings, err
+		}
+	}
+
+	return strings.TrimSpace(buildDirectiveRegexp.ReplaceAllLiteralString(version, "")), settings, nil
+}
+
+func (s BuildSettings) IsZero() bool {
+	return len(s.Tags) == 0 && len(s.LDFlags) == 0 && len(s.Env) == 0
+}
+
+// Merge returns s with other's settings added: tags are combined, ldflags appended, and env
+// entries of other win over those of s for the same variable
+func (s BuildSettings) Merge(other BuildSettings) BuildSettings {
+	merged := BuildSettings{}
+
+	for _, tag := range append(append([]string{}, s.Tags...), other.Tags...) {
+		if !stringInSlice(tag, merged.Tags) {
+			merged.Tags = append(merged.Tags, tag)
+		}
+	}
+
+	merged.LDFlags = append(append(merged.LDFlags, s.LDFlags...), other.LDFlags...)
+
+	for _, entry := range s.Env {
+		name := strings.SplitN(entry, "=", 2)[0]
+		if !envContains(other.Env, name) {
+			merged.Env = append(merged.Env, entry)
+		}
+	}
+	merged.Env = append(merged.Env, other.Env...)
+
+	return merged
+}
+
+func envContains(env []string, name string) bool {
+	for _, entry := range env {
+		if strings.HasPrefix(entry, name+"=") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s BuildSettings) args() []string { // go build flags, e.g. -tags netgo -ldflags "-s -w"
+	args := []string{}
+
+	if len(s.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(s.Tags, ","))
+	}
+	if len(s.LDFlags) > 0 {
+		args = append(args, "-ldflags", strings.Join(s.LDFlags, " "))
+	}
+
+	return args
+}
+
+func (s BuildSettings) goFlags() ([]string, error) { // the same flags as GOFLAGS entries, quoted if needed
+	flags := []string{}
+
+	if len(s.Tags) > 0 {
+		flags = append(flags, "-tags="+strings.Join(s.Tags, ","))
+	}
+	if len(s.LDFlags) > 0 {
+		flags = append(flags, "-ldflags="+strings.Join(s.LDFlags, " "))
+	}
+
+	for i, flag := range flags {
+		quoted, err := quoteGoFlag(flag)
+		if err != nil {
+			return nil, err
+		}
+		flags[i] = quoted
+	}
+
+	return flags, nil
+}
+
+// quoteGoFlag quotes flag the way go splits GOFLAGS: on whitespace, with a field wrapped in single
+// or double quotes taken literally, and no escapes
+func quoteGoFlag(flag string) (string, error) {
+	hasSingle, hasDouble := strings.Contains(flag, "'"), strings.Contains(flag, `"`)
+
+	switch {
+	case strings.IndexFunc(flag, unicode.IsSpace) < 0 && !hasSingle && !hasDouble:
+		return flag, nil
+	case !hasSingle:
+		return "'" + flag + "'", nil
+	case !hasDouble:
+		return `"` + flag + `"`, nil
+	default:
+		return "", fmt.Errorf("%s can't be passed in GOFLAGS, it contains both single and double quotes", flag)
+	}
+}
+
+func (s BuildSettings) directives() []string { // as written after a package in the Bunchfile
+	directives := []string{}
+
+	if len(s.Tags) > 0 {
+		directives = append(directives, "!tags:"+strings.Join(s.Tags, ","))
+	}
+	for _, ldflags := range s.LDFlags {
+		directives = append(directives, "!ldflags:"+quoteIfNeeded(ldflags))
+	}
+	for _, entry := range s.Env {
+		directives = append(directives, "!env:"+quoteIfNeeded(entry))
+	}
+
+	return directives
+}
@@ -0,0 +1,40 @@
+package bunch
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildSettingsArgs(t *testing.T) {
+	settings := BuildSettings{Tags: []string{"netgo", "osusergo"}, LDFlags: []string{"-s -w", "-X=main.version=1.0,beta"}, Env: []string{"CGO_CFLAGS=-O2 -g"}}
+
+	assert.Equal(t, []string{"-tags", "netgo,osusergo", "-ldflags", "-s -w -X=main.version=1.0,beta"}, settings.args(), "settings should become go build flags")
+	flags, err := settings.goFlags()
+	assert.Nil(t, err, "settings should fit in GOFLAGS")
+	assert.Equal(t, []string{"-tags=netgo,osusergo", `'-ldflags=-s -w -X=main.version=1.0,beta'`}, flags, "ldflags should be quoted for GOFLAGS")
+	assert.Equal(t, []string{}, BuildSettings{}.args(), "no settings should add no flags")
+
+	version, parsed, err := parseBuildDirectives("v1.0 " + strings.Join(settings.directives(), " "))
+	assert.Nil(t, err, "written directives should parse")
+	assert.Equal(t, "v1.0", version, "directives should be stripped from version")
+	assert.Equal(t, settings, parsed, "directives should parse back to the same settings")
+}
+
+func TestQuoteGoFlag(t *testing.T) {
+	quoted, err := quoteGoFlag(`-ldflags=-X=main.dir=C:\go`)
+	assert.Nil(t, err, "backslashes need no quoting")
+	assert.Equal(t, `-ldflags=-X=main.dir=C:\go`, quoted, "backslashes should be kept as they are")
+
+	quoted, err = quoteGoFlag(`-ldflags=-X "main.name=a b"`)
+	assert.Nil(t, err, "double quotes should be wrapped in single quotes")
+	assert.Equal(t, `'-ldflags=-X "main.name=a b"'`, quoted, "double quotes should be kept as they are")
+
+	quoted, err = quoteGoFlag(`-ldflags=-X main.name=it's`)
+	assert.Nil(t, err, "single quotes should be wrapped in double quotes")
+	assert.Equal(t, `"-ldflags=-X main.name=it's"`, quoted, "single quotes should be kept as they are")
+
+	_, err = quoteGoFlag(`-ldflags=-X "main.name=it's"`)
+	assert.NotNil(t, err, "both kinds of quotes can't be passed in GOFLAGS")
+}
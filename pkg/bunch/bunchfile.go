@@ -0,0 +1,394 @@
+package bunch
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+type Package struct {
+	Repo          string
+	Version       string
+	LockedVersion string
+	Branch        string
+	Build         BuildSettings // the package's own !tags, !ldflags and !env, without the Bunchfile's defaults
+
+	IsSelf     bool
+	IsLink     bool
+	LinkTarget string
+}
+
+type BunchFile struct {
+	Packages  []Package
+	Defaults  BuildSettings // from directives on lines of their own, applied to every package
+	GoVersion string        // from !go, e.g. 1.21.5 or 1.21 for any 1.21.x
+	Raw       []string
+
+	VendoredBunch bool // from !bunch vendored: commands run the bunch installed in the vendor directory
+
+	filename    string // where Save writes it
+	defaultHost string // the host of shorthand package names like a/b
+}
+
+var commentStripRegexp = regexp.MustCompile(`#.*`)
+var versionSwapRegexp = regexp.MustCompile(`^(\s*\S+)\s*(.*)`)
+var branchDirectiveRegexp = regexp.MustCompile(`\s*!branch:(\S+)`)
+
+func parseBranchDirective(version string) (string, string) { // returns version with directive removed, branch
+	match := branchDirectiveRegexp.FindStringSubmatch(version)
+	if match == nil {
+		return version, ""
+	}
+
+	return strings.TrimSpace(branchDirectiveRegexp.ReplaceAllLiteralString(version, "")), match[1]
+}
+
+// withBuildDefaults returns the packages with the Bunchfile's default build settings merged in
+func (b *BunchFile) withBuildDefaults() []Package {
+	packages := make([]Package, len(b.Packages))
+	for i, pack := range b.Packages {
+		pack.Build = b.Defaults.Merge(pack.Build)
+		packages[i] = pack
+	}
+
+	return packages
+}
+
+func (b *BunchFile) RawIndex(repo string) (int, bool) {
+	for i, packString := range b.Raw {
+		parts := strings.Fields(packString)
+
+		if len(parts) < 1 {
+			continue
+		}
+
+		if parts[0] == repo {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+func (b *BunchFile) PackageIndex(repo string) (int, bool) {
+	for i, pack := range b.Packages {
+		if pack.Repo == repo {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+func (b *BunchFile) AddPackage(packString string) error {
+	pack := parsePackage(packString, b.defaultHost)
+
+	index, present := b.RawIndex(pack.Repo)
+
+	if present {
+		packIndex, _ := b.PackageIndex(pack.Repo)
+
+		initialLine := b.Raw[index]
+
+		// keep any trailing comment, along with the spacing in front of it
+		var comment string
+		if commentIndex := strings.Index(initialLine, "#"); commentIndex >= 0 {
+			content := initialLine[:commentIndex]
+			trimmedContent := strings.TrimRight(content, " \t")
+
+			gap := content[len(trimmedContent):]
+			if gap == "" {
+				gap = " "
+			}
+
+			comment = gap + initialLine[commentIndex:]
+			initialLine = trimmedContent
+		}
+
+		pack.Branch = b.Packages[packIndex].Branch
+		pack.Build = b.Packages[packIndex].Build
+		b.Packages[packIndex] = pack
+
+		replacement := []string{"$1"}
+		if pack.Version != "" {
+			replacement = append(replacement, pack.Version)
+		}
+		if pack.Branch != "" {
+			replacement = append(replacement, fmt.Sprintf("!branch:%s", pack.Branch))
+		}
+		replacement = append(replacement, pack.Build.directives()...)
+
+		newLine := versionSwapRegexp.ReplaceAllString(initialLine, strings.Join(replacement, " "))
+
+		b.Raw[index] = newLine + comment
+	} else {
+		b.Packages = append(b.Packages, pack)
+		raw := []string{pack.Repo}
+		if pack.Version != "" {
+			raw = append(raw, pack.Version)
+		}
+
+		b.Raw = append(b.Raw, strings.Join(raw, " "))
+	}
+
+	return nil
+}
+
+func (b *BunchFile) RemovePackage(packString string) error {
+	pack := parsePackage(packString, b.defaultHost)
+
+	index, present := b.RawIndex(pack.Repo)
+
+	if present {
+		packIndex, packPresent := b.PackageIndex(pack.Repo)
+
+		if packPresent {
+			if packIndex < len(b.Packages)-1 {
+				b.Packages = append(b.Packages[:packIndex], b.Packages[packIndex+1:]...)
+			} else {
+				b.Packages = b.Packages[:packIndex]
+			}
+		}
+
+		if index < len(b.Raw)-1 {
+			b.Raw = append(b.Raw[:index], b.Raw[index+1:]...)
+		} else {
+			b.Raw = b.Raw[:index]
+		}
+	}
+
+	return nil
+}
+
+func (b *BunchFile) Save() error {
+	if b.filename == "" {
+		return errors.New("Bunchfile has no file to be saved to")
+	}
+
+	err := writeFileAtomic(b.filename, []byte(strings.Join(append(b.Raw, ""), "\n")), 0644)
+
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+func createBunchfile() *BunchFile {
+	return &BunchFile{}
+}
+
+func (e *engine) readBunchfile() (*BunchFile, error) {
+	bunch, err := readBunchfileAt(e.bunchfilePath(), e.bunchfileLockPath(), e.projectRoot)
+	if err != nil {
+		return bunch, err
+	}
+
+	bunch.filename, bunch.defaultHost = e.bunchfilePath(), e.defaultHost
+
+	return bunch, nil
+}
+
+func readBunchfileAt(filename string, lockFilename string, root string) (*BunchFile, error) { // relative !link targets are taken relative to root
+	bunchbytes, err := ioutil.ReadFile(filename)
+
+	if err != nil {
+		return &BunchFile{}, newError(KindBunchfile, "unable to read %s: %s", filename, err)
+	}
+
+	bunch := BunchFile{
+		Raw: strings.Split(strings.TrimSpace(string(bunchbytes)), "\n"),
+	}
+
+	lockedCommits := make(map[string]string)
+
+	if exists, _ := pathExists(lockFilename); exists {
+		lockBytes, err := ioutil.ReadFile(lockFilename)
+		if err != nil {
+			return &BunchFile{}, newError(KindBunchfile, "unable to read %s: %s", lockFilename, err)
+		}
+
+		err = json.Unmarshal(lockBytes, &lockedCommits)
+		if err != nil {
+			return &BunchFile{}, newError(KindBunchfile, "%s is not a valid lock file: %s", lockFilename, err)
+		}
+	}
+
+	for i, line := range bunch.Raw {
+		line = commentStripRegexp.ReplaceAllLiteralString(line, "")
+		line = strings.TrimSpace(line)
+
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "!") {
+			// a directive of its own, for the whole project: !tags netgo, !go 1.21.5
+			directive := strings.SplitN(strings.TrimPrefix(line, "!"), " ", 2)
+			if len(directive) < 2 {
+				directive = strings.SplitN(directive[0], ":", 2)
+			}
+
+			switch directive[0] {
+			case "bunch":
+				if len(directive) < 2 || strings.TrimSpace(directive[1]) != "vendored" {
+					return &BunchFile{}, newError(KindBunchfile, "%s:%d: expected !bunch vendored", filename, i+1)
+				}
+
+				bunch.VendoredBunch = true
+			case "go":
+				if len(directive) < 2 {
+					return &BunchFile{}, newError(KindBunchfile, "%s:%d: !go needs a version", filename, i+1)
+				}
+
+				bunch.GoVersion = strings.TrimSpace(directive[1])
+
+				err = validateGoVersion(bunch.GoVersion)
+				if err != nil {
+					return &BunchFile{}, newError(KindBunchfile, "%s:%d: %s", filename, i+1, err)
+				}
+			case "tags", "ldflags", "env":
+				value := ""
+				if len(directive) == 2 {
+					value = directive[1]
+				}
+
+				err = bunch.Defaults.add(directive[0], value)
+				if err != nil {
+					return &BunchFile{}, newError(KindBunchfile, "%s:%d: %s", filename, i+1, err)
+				}
+			default:
+				return &BunchFile{}, newError(KindBunchfile, "%s:%d: unknown directive !%s", filename, i+1, directive[0])
+			}
+
+			continue
+		}
+
+		pack := Package{}
+
+		packageInfo := strings.SplitN(line, " ", 2)
+
+		if len(packageInfo) < 1 {
+			continue
+		} else {
+			pack.Repo = packageInfo[0]
+		}
+
+		if len(packageInfo) >= 2 {
+			pack.Version, pack.Branch = parseBranchDirective(strings.TrimSpace(packageInfo[1]))
+
+			pack.Version, pack.Build, err = parseBuildDirectives(pack.Version)
+			if err != nil {
+				return &BunchFile{}, newError(KindBunchfile, "%s:%d: %s", filename, i+1, err)
+			}
+		}
+
+		if strings.HasPrefix(pack.Version, "!") && !strings.HasPrefix(pack.Version, "!link") && !strings.HasPrefix(pack.Version, "!self") {
+			return &BunchFile{}, newError(KindBunchfile, "%s:%d: unknown directive %s", filename, i+1, strings.Fields(pack.Version)[0])
+		}
+
+		if strings.HasPrefix(pack.Version, "!link") || strings.HasPrefix(pack.Version, "!self") {
+			if strings.HasPrefix(pack.Version, "!self") {
+				pack.IsSelf = true
+			}
+
+			pack.IsLink = true
+
+			linkList := strings.Split(pack.Version, ":")
+
+			if len(linkList) == 2 {
+				pack.LinkTarget = linkList[1]
+
+				if !filepath.IsAbs(pack.LinkTarget) {
+					pack.LinkTarget = path.Join(root, pack.LinkTarget)
+				}
+			} else {
+				pack.LinkTarget = root
+			}
+		}
+
+		if lockedCommits[pack.Repo] != "" {
+			pack.LockedVersion = lockedCommits[pack.Repo]
+		}
+
+		bunch.Packages = append(bunch.Packages, pack)
+	}
+
+	return &bunch, nil
+}
+
+func filterCommonBasePackages(depList []string, selfBase string) []string {
+	basePackages := []string{}
+
+	for i, dep1 := range depList {
+		foundPrefix := false
+
+		if strings.HasPrefix(dep1, selfBase) {
+			continue
+		}
+
+		for j, dep2 := range depList {
+			if i == j {
+				continue
+			}
+
+			if strings.HasPrefix(dep1, dep2) {
+				foundPrefix = true
+				break
+			}
+		}
+
+		if !foundPrefix {
+			basePackages = append(basePackages, dep1)
+		}
+	}
+
+	return basePackages
+}
+
+func (e *engine) generateBunchfile() error {
+	bunch := BunchFile{filename: e.bunchfilePath(), defaultHost: e.defaultHost}
+
+	output, err := e.commandOutput(e.command(e.projectRoot, "go", "list", "--json", "."))
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	packageInfo := GoList{}
+	err = json.Unmarshal(output, &packageInfo)
+
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = bunch.AddPackage(fmt.Sprintf("%s@!self", packageInfo.ImportPath))
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	for _, dep := range filterCommonBasePackages(append(packageInfo.Deps, packageInfo.TestImports...), packageInfo.ImportPath) {
+		// check that the package is not part of the standard library
+		if exists, _ := pathExists(path.Join(build.Default.GOROOT, "src", dep)); !exists {
+			err = bunch.AddPackage(dep)
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+
+	err = bunch.Save()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	e.reporter.Message(MessageSuccess, "Bunchfile generated successfully")
+
+	return nil
+}
@@ -0,0 +1,261 @@
+package bunch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterCommonBasePackages(t *testing.T) {
+	depList := []string{"github.com/a/b/c", "github.com/d/e/f", "github.com/a/b"}
+	result := filterCommonBasePackages(depList, "github.com/my/package")
+
+	expectedResult := []string{"github.com/d/e/f", "github.com/a/b"}
+
+	assert.Equal(t, result, expectedResult, "packages with a common base should be filtered out")
+}
+
+func TestCreateBunchfile(t *testing.T) {
+	assert.Equal(t, &BunchFile{}, createBunchfile(), "create bunchfile returns a bunchfile object")
+}
+
+func TestRawIndex(t *testing.T) {
+	bunch := createBunchfile()
+
+	bunch.Raw = []string{"github.com/a/b", "github.com/a/b/c", "github.com/d 123456"}
+
+	rawIndex, present := bunch.RawIndex("github.com/a/b")
+	assert.Equal(t, rawIndex, 0, "found github.com/a/b at first position in bunchfile")
+	assert.Equal(t, present, true, "found github.com/a/b at first position in bunchfile")
+
+	rawIndex, present = bunch.RawIndex("github.com/d")
+	assert.Equal(t, rawIndex, 2, "found github.com/d at third position in bunchfile")
+	assert.Equal(t, present, true, "found github.com/a/b at first position in bunchfile")
+
+	rawIndex, present = bunch.RawIndex("github.com/a/b/c")
+	assert.Equal(t, rawIndex, 1, "found github.com/a/b/c at second position in bunchfile")
+	assert.Equal(t, present, true, "found github.com/a/b/c in bunchfile")
+
+	_, present = bunch.RawIndex("github.com/unknown")
+	assert.Equal(t, present, false, "did not find github.com/unknown in bunchfile")
+}
+
+func TestPackageIndex(t *testing.T) {
+	bunch := createBunchfile()
+
+	bunch.Packages = []Package{
+		Package{
+			Repo:    "github.com/a/b",
+			Version: "123",
+		},
+		Package{
+			Repo: "github.com/def",
+		},
+		Package{
+			Repo:    "github.com/a/b/c",
+			Version: "xyz",
+		},
+	}
+
+	packageIndex, present := bunch.PackageIndex("github.com/a/b")
+	assert.Equal(t, packageIndex, 0, "found github.com/a/b in first position in bunchfile package list")
+	assert.Equal(t, present, true, "found github.com/a/b in bunchfile package list")
+
+	packageIndex, present = bunch.PackageIndex("github.com/def")
+	assert.Equal(t, packageIndex, 1, "found github.com/def at second position in bunchfile package list")
+	assert.Equal(t, present, true, "found github.com/def in bunchfile package list")
+
+	packageIndex, present = bunch.PackageIndex("github.com/a/b/c")
+	assert.Equal(t, packageIndex, 2, "found github.com/a/b/c at third position in bunchfile package list")
+	assert.Equal(t, present, true, "found github.com/a/b/c in bunchfile package list")
+
+	_, present = bunch.PackageIndex("github.com/unknown")
+	assert.Equal(t, present, false, "did not find github.com/unknown in bunchfile package list")
+}
+
+func TestAddPackageNew(t *testing.T) {
+	bunch := createBunchfile()
+
+	err := bunch.AddPackage("github.com/a/b/c")
+	assert.Nil(t, err, "did not error on adding package")
+
+	packageIndex, present := bunch.PackageIndex("github.com/a/b/c")
+	assert.Equal(t, packageIndex, 0, "found github.com/a/b/c in first position in bunchfile package list")
+	assert.Equal(t, present, true, "found github.com/a/b/c in bunchfile package list")
+
+	err = bunch.AddPackage("github.com/a/b/d@v1.2.0")
+	assert.Nil(t, err, "did not error on adding package")
+
+	packageIndex, present = bunch.PackageIndex("github.com/a/b/d")
+	assert.Equal(t, packageIndex, 1, "found github.com/a/b/d in second position in bunchfile package list")
+	assert.Equal(t, present, true, "found github.com/a/b/d in bunchfile package list")
+}
+
+func TestAddPackageUpdate(t *testing.T) {
+	bunch := createBunchfile()
+
+	err := bunch.AddPackage("github.com/a/b/c")
+	assert.Nil(t, err, "did not error on adding package")
+
+	pack := bunch.Packages[0]
+	assert.Equal(t, len(bunch.Packages), 1, "should only be one package in bunchfile")
+	assert.Equal(t, "", pack.Version, "version should not be set yet")
+
+	err = bunch.AddPackage("github.com/a/b/c@v1.2.1")
+	assert.Nil(t, err, "did not error on adding package")
+
+	pack = bunch.Packages[0]
+	assert.Equal(t, len(bunch.Packages), 1, "should still be only one package in bunchfile")
+	assert.Equal(t, pack.Version, "v1.2.1", "version should now be set")
+}
+
+func TestRemovePackage(t *testing.T) {
+	bunch := createBunchfile()
+
+	err := bunch.AddPackage("github.com/a/b/c")
+	assert.Nil(t, err, "did not error on adding package")
+
+	assert.Equal(t, len(bunch.Raw), 1, "should be one packages in Bunchfile")
+	assert.Equal(t, len(bunch.Packages), 1, "should be one package in bunchfile package list")
+
+	err = bunch.RemovePackage("github.com/a/b/c")
+	assert.Nil(t, err, "did not error on removing package")
+
+	assert.Equal(t, len(bunch.Raw), 0, "should be no packages in Bunchfile")
+	assert.Equal(t, len(bunch.Packages), 0, "should be no packages in Bunchfile package list")
+}
+
+func TestParseBranchDirective(t *testing.T) {
+	version, branch := parseBranchDirective(">= 1.0 !branch:develop")
+	assert.Equal(t, ">= 1.0", version, "branch directive should be stripped from version")
+	assert.Equal(t, "develop", branch, "branch should be parsed")
+
+	version, branch = parseBranchDirective("!branch:trunk")
+	assert.Equal(t, "", version, "version should be empty")
+	assert.Equal(t, "trunk", branch, "branch should be parsed")
+
+	version, branch = parseBranchDirective("v1.2.0")
+	assert.Equal(t, "v1.2.0", version, "version should be untouched")
+	assert.Equal(t, "", branch, "no branch should be parsed")
+}
+
+func TestAddPackageKeepsBranch(t *testing.T) {
+	bunch := createBunchfile()
+	bunch.Raw = []string{"github.com/a/b !branch:develop"}
+	bunch.Packages = []Package{Package{Repo: "github.com/a/b", Branch: "develop"}}
+
+	err := bunch.AddPackage("github.com/a/b@v1.2.0")
+	assert.Nil(t, err, "did not error on adding package")
+
+	assert.Equal(t, "github.com/a/b v1.2.0 !branch:develop", bunch.Raw[0], "branch directive should be kept")
+	assert.Equal(t, "develop", bunch.Packages[0].Branch, "branch should be kept")
+	assert.Equal(t, "v1.2.0", bunch.Packages[0].Version, "version should be updated")
+}
+
+func TestAddPackageKeepsComment(t *testing.T) {
+	bunch := createBunchfile()
+	bunch.Raw = []string{"github.com/a/b ~> 1.2   # pinned for the old API"}
+	bunch.Packages = []Package{Package{Repo: "github.com/a/b", Version: "~> 1.2"}}
+
+	err := bunch.AddPackage("github.com/a/b@~> 2.0")
+	assert.Nil(t, err, "did not error on adding package")
+
+	assert.Equal(t, "github.com/a/b ~> 2.0   # pinned for the old API", bunch.Raw[0], "comment and spacing should be kept")
+}
+
+func TestReadBunchfileRejectsInvalidFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bunch-bunchfile")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	bunchfile := path.Join(dir, "Bunchfile")
+	lockfile := path.Join(dir, "Bunchfile.lock")
+
+	_ = ioutil.WriteFile(bunchfile, []byte("github.com/a/b\ngithub.com/c/d !lnk:../d\n"), 0644)
+
+	_, err = readBunchfileAt(bunchfile, lockfile, dir)
+	assert.Equal(t, KindBunchfile, KindOf(err), "unknown directives should be rejected")
+	assert.Contains(t, err.Error(), "Bunchfile:2", "error should name the line")
+
+	_ = ioutil.WriteFile(bunchfile, []byte("github.com/a/b !branch:develop\n"), 0644)
+	_ = ioutil.WriteFile(lockfile, []byte("{not json"), 0644)
+
+	_, err = readBunchfileAt(bunchfile, lockfile, dir)
+	assert.Equal(t, KindBunchfile, KindOf(err), "invalid lock files should be rejected")
+
+	_ = os.Remove(lockfile)
+
+	b, err := readBunchfileAt(bunchfile, lockfile, dir)
+	assert.Nil(t, err, "!branch should be accepted")
+	assert.Equal(t, "develop", b.Packages[0].Branch, "branch should be parsed")
+}
+
+func TestReadBunchfileBuildSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bunch-bunchfile")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	bunchfile := path.Join(dir, "Bunchfile")
+	lockfile := path.Join(dir, "Bunchfile.lock")
+
+	_ = ioutil.WriteFile(bunchfile, []byte(`!tags netgo
+!env CGO_ENABLED=0
+!ldflags -X main.version=1.0
+github.com/a/b ~> 1.2 !tags:sqlite_json,fts5 !ldflags:"-s -w -extldflags=-Wl,-z,relro" !branch:develop
+github.com/c/d !env:CGO_ENABLED=1 !env:GOAMD64=v3
+`), 0644)
+
+	b, err := readBunchfileAt(bunchfile, lockfile, dir)
+	assert.Nil(t, err, "build directives should be accepted")
+	assert.Equal(t, BuildSettings{Tags: []string{"netgo"}, LDFlags: []string{"-X main.version=1.0"}, Env: []string{"CGO_ENABLED=0"}}, b.Defaults, "lines of their own should be defaults")
+	assert.Equal(t, "~> 1.2", b.Packages[0].Version, "directives should be stripped from version")
+	assert.Equal(t, "develop", b.Packages[0].Branch, "branch should still be parsed")
+	assert.Equal(t, BuildSettings{Tags: []string{"sqlite_json", "fts5"}, LDFlags: []string{"-s -w -extldflags=-Wl,-z,relro"}}, b.Packages[0].Build, "quoted ldflags should be kept as written, commas and all")
+	assert.Equal(t, "", b.Packages[1].Version, "version should be empty")
+
+	packages := b.withBuildDefaults()
+	assert.Equal(t, []string{"netgo", "sqlite_json", "fts5"}, packages[0].Build.Tags, "default tags should be added")
+	assert.Equal(t, []string{"CGO_ENABLED=1", "GOAMD64=v3"}, packages[1].Build.Env, "package env should win over defaults")
+
+	_ = ioutil.WriteFile(bunchfile, []byte("github.com/a/b !env:CGO_ENABLED\n"), 0644)
+	_, err = readBunchfileAt(bunchfile, lockfile, dir)
+	assert.Equal(t, KindBunchfile, KindOf(err), "env without a value should be rejected")
+
+	_ = ioutil.WriteFile(bunchfile, []byte("!go 1.21.5\ngithub.com/a/b\n"), 0644)
+	b, err = readBunchfileAt(bunchfile, lockfile, dir)
+	assert.Nil(t, err, "!go should be accepted")
+	assert.Equal(t, "1.21.5", b.GoVersion, "go version should be parsed")
+
+	_ = ioutil.WriteFile(bunchfile, []byte("!bunch vendored\ngithub.com/dkulchenko/bunch v0.7\n"), 0644)
+	b, err = readBunchfileAt(bunchfile, lockfile, dir)
+	assert.Nil(t, err, "!bunch vendored should be accepted")
+	assert.True(t, b.VendoredBunch, "vendored bunch should be asked for")
+
+	_ = ioutil.WriteFile(bunchfile, []byte("!bunch global\n"), 0644)
+	_, err = readBunchfileAt(bunchfile, lockfile, dir)
+	assert.Equal(t, KindBunchfile, KindOf(err), "unknown !bunch values should be rejected")
+
+	_ = ioutil.WriteFile(bunchfile, []byte("!go latest\n"), 0644)
+	_, err = readBunchfileAt(bunchfile, lockfile, dir)
+	assert.Equal(t, KindBunchfile, KindOf(err), "invalid go versions should be rejected")
+
+	_ = ioutil.WriteFile(bunchfile, []byte("!tag netgo\n"), 0644)
+	_, err = readBunchfileAt(bunchfile, lockfile, dir)
+	assert.Contains(t, fmt.Sprint(err), "Bunchfile:1: unknown directive !tag", "unknown project directives should be rejected")
+}
+
+func TestAddPackageKeepsBuildSettings(t *testing.T) {
+	bunch := createBunchfile()
+	bunch.Raw = []string{"github.com/a/b ~> 1.2 !tags:netgo !env:CGO_ENABLED=0"}
+	bunch.Packages = []Package{Package{Repo: "github.com/a/b", Version: "~> 1.2", Build: BuildSettings{Tags: []string{"netgo"}, Env: []string{"CGO_ENABLED=0"}}}}
+
+	err := bunch.AddPackage("github.com/a/b@~> 2.0")
+	assert.Nil(t, err, "did not error on adding package")
+
+	assert.Equal(t, "github.com/a/b ~> 2.0 !tags:netgo !env:CGO_ENABLED=0", bunch.Raw[0], "build directives should be kept")
+	assert.Equal(t, []string{"netgo"}, bunch.Packages[0].Build.Tags, "build settings should be kept")
+}
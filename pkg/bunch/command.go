@@ -0,0 +1,276 @@
+package bunch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const failureTailLines = 20
+
+// how long output is still read after a command exits or is killed; helpers it started (e.g.
+// git-remote-https) may hold it open
+var commandWaitDelay = time.Second
+var retryDelay = time.Second
+var maxRetryDelay = 30 * time.Second
+
+// commandError describes an external command that failed, along with the end of its output
+type commandError struct {
+	command    string
+	dir        string
+	exitStatus int // -1 if the command couldn't be started or didn't exit normally
+	output     []byte
+	logPath    string
+	timeout    time.Duration
+	err        error
+}
+
+func (e *commandError) Error() string {
+	var message bytes.Buffer
+
+	switch {
+	case e.err == context.DeadlineExceeded:
+		fmt.Fprintf(&message, "`%s` timed out after %s", e.command, e.timeout)
+	case e.err == context.Canceled:
+		fmt.Fprintf(&message, "`%s` was interrupted", e.command)
+	case e.exitStatus >= 0:
+		fmt.Fprintf(&message, "`%s` failed with exit status %d", e.command, e.exitStatus)
+	default:
+		fmt.Fprintf(&message, "`%s` failed: %s", e.command, e.err)
+	}
+
+	fmt.Fprintf(&message, "\n    in %s", e.dir)
+
+	if tail := tailLines(string(e.output), failureTailLines); tail != "" {
+		fmt.Fprintf(&message, "\n    output:")
+		for _, line := range strings.Split(tail, "\n") {
+			fmt.Fprintf(&message, "\n        %s", line)
+		}
+	}
+
+	if e.logPath != "" {
+		fmt.Fprintf(&message, "\n    full log: %s", e.logPath)
+	}
+
+	return message.String()
+}
+
+func commandDir(cmd *exec.Cmd) string {
+	if cmd.Dir != "" {
+		return cmd.Dir
+	}
+
+	wd, _ := os.Getwd()
+	return wd
+}
+
+// command is exec.Command with the operation's environment, run in dir (the working directory if
+// empty). name is looked up on that environment's PATH, so the vendored binaries and the
+// Bunchfile's Go version are found.
+func (e *engine) command(dir string, name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Env = e.env
+
+	cmd.Path, cmd.Err = LookPath(name, e.env)
+
+	return cmd
+}
+
+func commandLine(cmd *exec.Cmd) string {
+	return strings.Join(cmd.Args, " ")
+}
+
+// execute runs cmd, killing it when the running operation is cancelled or, if timeout isn't
+// zero, once it has run that long. A killed command returns the context's error.
+func (e *engine) execute(cmd *exec.Cmd, stdout io.Writer, stderr io.Writer, timeout time.Duration) error {
+	ctx := e.ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = commandWaitDelay
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-exited:
+		if err != nil && ctx.Err() != nil { // e.g. git exiting on the same Ctrl-C that cancelled the operation
+			return ctx.Err()
+		}
+		return err
+	case <-ctx.Done():
+		_ = cmd.Process.Kill()
+		<-exited
+		return ctx.Err()
+	}
+}
+
+func (e *engine) runCommandWithin(cmd *exec.Cmd, timeout time.Duration) ([]byte, error) {
+	var output bytes.Buffer
+
+	started := time.Now()
+	err := e.execute(cmd, &output, &output, timeout)
+	e.runLog.record(commandLine(cmd), commandDir(cmd), output.Bytes(), err, time.Since(started))
+
+	if err != nil {
+		exitStatus := -1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitStatus = exitErr.ExitCode()
+		}
+
+		return output.Bytes(), &commandError{command: commandLine(cmd), dir: commandDir(cmd), exitStatus: exitStatus, output: output.Bytes(), logPath: e.runLog.logPath(), timeout: timeout, err: err}
+	}
+
+	return output.Bytes(), nil
+}
+
+func (e *engine) commandOutputWithin(cmd *exec.Cmd, timeout time.Duration) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	started := time.Now()
+	err := e.execute(cmd, &stdout, &stderr, timeout)
+	e.runLog.record(commandLine(cmd), commandDir(cmd), append(stdout.Bytes(), stderr.Bytes()...), err, time.Since(started))
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		exitErr.Stderr = stderr.Bytes()
+	}
+
+	return stdout.Bytes(), err
+}
+
+// runCommand runs cmd and returns its combined output. The output is recorded in the run log,
+// and failures are returned as a commandError showing the command and the end of its output.
+func (e *engine) runCommand(cmd *exec.Cmd) ([]byte, error) {
+	return e.runCommandWithin(cmd, 0)
+}
+
+// commandOutput runs cmd like cmd.Output, for commands whose output is parsed, recording stdout
+// and stderr in the run log
+func (e *engine) commandOutput(cmd *exec.Cmd) ([]byte, error) {
+	return e.commandOutputWithin(cmd, 0)
+}
+
+// transientOutputs are what git, hg and bzr print when the connection failed rather than the
+// command, lowercased
+var transientOutputs = []string{
+	"could not resolve host",
+	"temporary failure in name resolution",
+	"connection timed out",
+	"operation timed out",
+	"connection refused",
+	"connection reset",
+	"network is unreachable",
+	"the remote end hung up unexpectedly",
+	"early eof",
+	"rpc failed",
+	"gnutls_handshake",
+	"ssl_connect",
+	"ssl_read",
+	"the requested url returned error: 5",
+}
+
+// transientError marks a failure worth retrying that isn't recognizable from its cause alone,
+// e.g. a server error response
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+// isTransient reports whether err is a timeout or a failed connection, which may go away on its
+// own, rather than a command or response that failed and would fail the same way again
+func isTransient(err error) bool {
+	switch cause := errors.Cause(err).(type) {
+	case *transientError:
+		return true
+	case net.Error:
+		return true
+	case *commandError:
+		if cause.err == context.DeadlineExceeded {
+			return true
+		}
+
+		output := strings.ToLower(string(cause.output))
+		for _, message := range transientOutputs {
+			if strings.Contains(output, message) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// withNetworkRetries calls attempt until it succeeds, retrying timeouts and connection failures
+// up to the configured number of times with exponential backoff. Other failures are returned
+// right away, and nothing is retried once the operation is cancelled.
+func (e *engine) withNetworkRetries(action string, attempt func() error) error {
+	delay := retryDelay
+
+	for retry := 0; ; retry++ {
+		err := attempt()
+		if err == nil || retry >= e.networkRetries || e.ctx.Err() != nil || !isTransient(err) {
+			return err
+		}
+
+		e.reporter.Message(MessageWarning, fmt.Sprintf("%s failed, retrying in %s (%d of %d)", action, delay, retry+1, e.networkRetries))
+
+		select {
+		case <-e.ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// runNetworkCommand runs a clone, fetch or similar like runCommand, killing it after the network
+// timeout and retrying timeouts and connection failures. newCmd is called for every attempt, so it
+// can clean up after a failed one.
+func (e *engine) runNetworkCommand(newCmd func() *exec.Cmd) ([]byte, error) {
+	var output []byte
+
+	cmd := newCmd()
+	err := e.withNetworkRetries(commandLine(cmd), func() (err error) {
+		if cmd == nil {
+			cmd = newCmd()
+		}
+
+		output, err = e.runCommandWithin(cmd, e.networkTimeout)
+		cmd = nil
+
+		return err
+	})
+
+	return output, err
+}
@@ -0,0 +1,89 @@
+package bunch
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCommandTimeout(t *testing.T) {
+	e := testEngine()
+
+	started := time.Now()
+	_, err := e.runCommandWithin(e.command("", "sleep", "10"), 100*time.Millisecond)
+
+	assert.True(t, time.Since(started) < 5*time.Second, "command should be killed after the timeout")
+	assert.Contains(t, fmt.Sprint(err), "`sleep 10` timed out after 100ms", "timeout should be reported")
+}
+
+func TestRunCommandCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	e := testEngine()
+	e.ctx = ctx
+
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	started := time.Now()
+	_, err := e.runCommand(e.command("", "sleep", "10"))
+
+	assert.True(t, time.Since(started) < 5*time.Second, "command should be killed when the operation is cancelled")
+	assert.Contains(t, fmt.Sprint(err), "`sleep 10` was interrupted", "interruption should be reported")
+
+	_, err = e.runCommand(e.command("", "true"))
+	assert.Equal(t, context.Canceled, err.(*commandError).err, "no commands should start once cancelled")
+}
+
+func TestWithNetworkRetries(t *testing.T) {
+	oldDelay := retryDelay
+	retryDelay = time.Millisecond
+	defer func() { retryDelay = oldDelay }()
+
+	e := testEngine()
+	e.networkRetries = 2
+
+	unreachable := &transientError{err: fmt.Errorf("unreachable")}
+
+	attempts := 0
+	err := e.withNetworkRetries("fetching", func() error {
+		attempts++
+		if attempts < 3 {
+			return unreachable
+		}
+		return nil
+	})
+	assert.Nil(t, err, "third attempt should succeed")
+	assert.Equal(t, 3, attempts, "failures should be retried")
+
+	attempts = 0
+	err = e.withNetworkRetries("fetching", func() error {
+		attempts++
+		return unreachable
+	})
+	assert.Equal(t, "unreachable", fmt.Sprint(err), "last failure should be returned")
+	assert.Equal(t, 3, attempts, "retries should stop after the configured number")
+
+	attempts = 0
+	err = e.withNetworkRetries("fetching", func() error {
+		attempts++
+		return fmt.Errorf("repository not found")
+	})
+	assert.Equal(t, "repository not found", fmt.Sprint(err), "failure should be returned")
+	assert.Equal(t, 1, attempts, "failures that aren't transient shouldn't be retried")
+}
+
+func TestIsTransient(t *testing.T) {
+	assert.True(t, isTransient(&commandError{err: context.DeadlineExceeded}), "timeouts should be retried")
+	assert.True(t, isTransient(&commandError{exitStatus: 128, output: []byte("fatal: unable to access 'https://example.com/a.git/': Could not resolve host: example.com")}), "connection failures should be retried")
+	assert.True(t, isTransient(errors.Annotate(&net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")}, "lookup")), "transport errors should be retried")
+	assert.True(t, isTransient(&transientError{err: fmt.Errorf("503 Service Unavailable")}), "errors marked transient should be retried")
+
+	assert.False(t, isTransient(&commandError{exitStatus: 128, output: []byte("fatal: repository 'https://example.com/openssl.git/' not found")}), "missing repositories shouldn't be retried")
+	assert.False(t, isTransient(&commandError{exitStatus: -1, err: fmt.Errorf("executable file not found")}), "commands that can't start shouldn't be retried")
+	assert.False(t, isTransient(errors.Annotate(fmt.Errorf("XML syntax error"), "failed parsing")), "parse failures shouldn't be retried")
+}
@@ -0,0 +1,270 @@
+package bunch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+type ConfigKey struct {
+	Name     string
+	Env      []string // checked in order, the first non-empty one wins
+	Default  string
+	Usage    string
+	Validate func(string) error
+}
+
+var ConfigKeys = []ConfigKey{
+	{Name: "vendor_dir", Env: []string{"BUNCH_VENDOR_DIR"}, Default: ".vendor", Usage: "directory dependencies are vendored into, relative to the project root"},
+	{Name: "parallelism", Env: []string{"BUNCH_PARALLELISM"}, Default: "1", Usage: "number of repositories fetched at once", Validate: validatePositiveInt},
+	{Name: "default_host", Env: []string{"BUNCH_DEFAULT_HOST"}, Default: "github.com", Usage: "host used to expand the a/b package shorthand"},
+	{Name: "color", Env: []string{"BUNCH_COLOR"}, Default: "auto", Usage: "colored output: auto, always or never", Validate: validateOneOf("auto", "always", "never")},
+	{Name: "spinner", Env: []string{"BUNCH_SPINNER"}, Default: "true", Usage: "animate running steps on terminals", Validate: validateBool},
+	{Name: "progress", Env: []string{"BUNCH_PROGRESS"}, Default: "auto", Usage: "progress output: auto, tty, plain, json (on stderr) or none", Validate: validateOneOf("auto", "tty", "plain", "json", "none")},
+	{Name: "lock_timeout", Env: []string{"BUNCH_LOCK_TIMEOUT"}, Default: "0s", Usage: "how long to wait for another bunch process to release the vendor lock", Validate: validateDuration},
+	{Name: "network_timeout", Env: []string{"BUNCH_NETWORK_TIMEOUT"}, Default: "5m", Usage: "how long a clone, fetch or remote lookup may take before it's killed (0 for no limit)", Validate: validateDuration},
+	{Name: "network_retries", Env: []string{"BUNCH_NETWORK_RETRIES"}, Default: "2", Usage: "how many times failed clones, fetches and remote lookups are retried, with exponential backoff", Validate: validateNonNegativeInt},
+	{Name: "go_toolchains", Env: []string{"BUNCH_GO_TOOLCHAINS"}, Usage: "directory of installed Go versions (e.g. ~/sdk holding go1.21.5/bin/go) to use for the Bunchfile's !go version"},
+	{Name: "mirror", Env: []string{"BUNCH_MIRROR"}, Usage: "comma-separated clone url rewrites, e.g. github.com/* -> https://git.internal/mirror/github.com/*", Validate: validateMirrorRules},
+	{Name: "http_proxy", Env: []string{"HTTP_PROXY", "http_proxy"}, Usage: "proxy for http fetches"},
+	{Name: "https_proxy", Env: []string{"HTTPS_PROXY", "https_proxy"}, Usage: "proxy for https fetches"},
+	{Name: "no_proxy", Env: []string{"NO_PROXY", "no_proxy"}, Usage: "hosts that bypass the proxy"},
+}
+
+var ProjectConfigName = ".bunchrc"
+
+// Config holds settings from ~/.bunch/config and the project's .bunchrc. Lookups apply the
+// precedence flags > environment > project config > user config > defaults; flags are
+// applied by the caller since only it knows whether one was given.
+type Config struct {
+	Values  map[string]string
+	Sources map[string]string
+}
+
+func FindConfigKey(name string) (ConfigKey, bool) {
+	for _, key := range ConfigKeys {
+		if key.Name == name {
+			return key, true
+		}
+	}
+
+	return ConfigKey{}, false
+}
+
+func validatePositiveInt(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return fmt.Errorf("%q is not a positive integer", value)
+	}
+	return nil
+}
+
+func validateNonNegativeInt(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fmt.Errorf("%q is not a non-negative integer", value)
+	}
+	return nil
+}
+
+func validateBool(value string) error {
+	_, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("%q is not true or false", value)
+	}
+	return nil
+}
+
+func validateDuration(value string) error {
+	_, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%q is not a duration (e.g. 30s, 2m)", value)
+	}
+	return nil
+}
+
+func validateOneOf(choices ...string) func(string) error {
+	return func(value string) error {
+		for _, choice := range choices {
+			if value == choice {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q must be one of %s", value, strings.Join(choices, ", "))
+	}
+}
+
+func UserConfigPath() string {
+	return path.Join(os.Getenv("HOME"), ".bunch", "config")
+}
+
+func ProjectConfigPath(root string) string {
+	return path.Join(root, ProjectConfigName)
+}
+
+func parseConfigLine(line string) (string, string, bool) {
+	line = strings.TrimSpace(commentStripRegexp.ReplaceAllLiteralString(line, ""))
+	if line == "" {
+		return "", "", false
+	}
+
+	parts := strings.SplitN(line, "=", 2)
+	if len(parts) != 2 {
+		return line, "", true
+	}
+
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), true
+}
+
+func (c *Config) loadFile(filename string) error {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Trace(err)
+	}
+
+	for i, line := range strings.Split(string(contents), "\n") {
+		name, value, ok := parseConfigLine(line)
+		if !ok {
+			continue
+		}
+
+		key, known := FindConfigKey(name)
+		if !known {
+			return newError(KindConfig, "%s:%d: unknown setting %q", filename, i+1, name)
+		}
+
+		if key.Validate != nil {
+			if err := key.Validate(value); err != nil {
+				return newError(KindConfig, "%s:%d: %s: %s", filename, i+1, name, err)
+			}
+		}
+
+		c.Values[name] = value
+		c.Sources[name] = filename
+	}
+
+	return nil
+}
+
+func LoadConfig(root string) (*Config, error) {
+	config := &Config{Values: map[string]string{}, Sources: map[string]string{}}
+
+	for _, filename := range []string{UserConfigPath(), ProjectConfigPath(root)} {
+		err := config.loadFile(filename)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	for _, key := range ConfigKeys {
+		if value, source := config.Lookup(key.Name); key.Validate != nil && strings.HasPrefix(source, "$") {
+			if err := key.Validate(value); err != nil {
+				return nil, newError(KindConfig, "%s: %s", source, err)
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// Lookup returns the effective value of a setting and where it came from
+func (c *Config) Lookup(name string) (string, string) {
+	key, _ := FindConfigKey(name)
+
+	for _, envName := range key.Env {
+		if value := os.Getenv(envName); value != "" {
+			return value, "$" + envName
+		}
+	}
+
+	if value, ok := c.Values[name]; ok {
+		return value, c.Sources[name]
+	}
+
+	return key.Default, "default"
+}
+
+func (c *Config) Get(name string) string {
+	value, _ := c.Lookup(name)
+	return value
+}
+
+func (c *Config) GetInt(name string) int {
+	n, err := strconv.Atoi(c.Get(name))
+	if err != nil {
+		key, _ := FindConfigKey(name)
+		n, _ = strconv.Atoi(key.Default)
+	}
+	return n
+}
+
+func (c *Config) GetBool(name string) bool {
+	b, err := strconv.ParseBool(c.Get(name))
+	if err != nil {
+		key, _ := FindConfigKey(name)
+		b, _ = strconv.ParseBool(key.Default)
+	}
+	return b
+}
+
+func (c *Config) GetDuration(name string) time.Duration {
+	d, err := time.ParseDuration(c.Get(name))
+	if err != nil {
+		key, _ := FindConfigKey(name)
+		d, _ = time.ParseDuration(key.Default)
+	}
+	return d
+}
+
+// SetConfigValue rewrites (or appends) a setting in a config file, leaving other lines alone
+func SetConfigValue(filename string, name string, value string) error {
+	key, known := FindConfigKey(name)
+	if !known {
+		return newError(KindConfig, "unknown setting %q", name)
+	}
+
+	if key.Validate != nil {
+		if err := key.Validate(value); err != nil {
+			return newError(KindConfig, "%s: %s", name, err)
+		}
+	}
+
+	lines := []string{}
+
+	contents, err := ioutil.ReadFile(filename)
+	if err == nil {
+		lines = strings.Split(strings.TrimRight(string(contents), "\n"), "\n")
+	} else if !os.IsNotExist(err) {
+		return errors.Trace(err)
+	}
+
+	newLine := fmt.Sprintf("%s = %s", name, value)
+	replaced := false
+
+	for i, line := range lines {
+		if lineName, _, ok := parseConfigLine(line); ok && lineName == name {
+			lines[i] = newLine
+			replaced = true
+		}
+	}
+
+	if !replaced {
+		lines = append(lines, newLine)
+	}
+
+	err = os.MkdirAll(path.Dir(filename), 0755)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return writeFileAtomic(filename, []byte(strings.Join(append(lines, ""), "\n")), 0644)
+}
@@ -0,0 +1,92 @@
+package bunch
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConfigLine(t *testing.T) {
+	name, value, ok := parseConfigLine("parallelism = 4 # fetch faster")
+	assert.True(t, ok, "setting line should parse")
+	assert.Equal(t, "parallelism", name, "name should be parsed")
+	assert.Equal(t, "4", value, "value should be parsed without the comment")
+
+	_, _, ok = parseConfigLine("   # just a comment")
+	assert.False(t, ok, "comment lines should be skipped")
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bunch-config")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	userConfig := path.Join(dir, "user")
+	projectConfig := path.Join(dir, "project")
+
+	_ = ioutil.WriteFile(userConfig, []byte("parallelism = 2\ndefault_host = git.example.com\n"), 0644)
+	_ = ioutil.WriteFile(projectConfig, []byte("parallelism = 8\n"), 0644)
+
+	config := &Config{Values: map[string]string{}, Sources: map[string]string{}}
+	assert.Nil(t, config.loadFile(userConfig), "user config should load")
+	assert.Nil(t, config.loadFile(projectConfig), "project config should load")
+
+	assert.Equal(t, 8, config.GetInt("parallelism"), "project config should override user config")
+	assert.Equal(t, "git.example.com", config.Get("default_host"), "user config should apply when project config is silent")
+	assert.Equal(t, "auto", config.Get("color"), "defaults should apply when nothing is set")
+
+	os.Setenv("BUNCH_PARALLELISM", "3")
+	defer os.Unsetenv("BUNCH_PARALLELISM")
+
+	value, source := config.Lookup("parallelism")
+	assert.Equal(t, "3", value, "environment should override config files")
+	assert.Equal(t, "$BUNCH_PARALLELISM", source, "source should name the environment variable")
+}
+
+func TestConfigRejectsInvalidValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bunch-config")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	configPath := path.Join(dir, ".bunchrc")
+
+	_ = ioutil.WriteFile(configPath, []byte("colour = never\n"), 0644)
+	config := &Config{Values: map[string]string{}, Sources: map[string]string{}}
+	assert.NotNil(t, config.loadFile(configPath), "unknown settings should be rejected")
+
+	assert.NotNil(t, SetConfigValue(configPath, "parallelism", "zero"), "invalid values should be rejected")
+}
+
+func TestSetConfigValue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bunch-config")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	configPath := path.Join(dir, ".bunchrc")
+	_ = ioutil.WriteFile(configPath, []byte("# team settings\nparallelism = 2\n"), 0644)
+
+	assert.Nil(t, SetConfigValue(configPath, "parallelism", "4"), "existing setting should be replaced")
+	assert.Nil(t, SetConfigValue(configPath, "color", "never"), "new setting should be appended")
+
+	contents, _ := ioutil.ReadFile(configPath)
+	assert.Equal(t, "# team settings\nparallelism = 4\ncolor = never\n", string(contents), "other lines should be kept")
+
+	assert.Equal(t, KindConfig, KindOf(SetConfigValue(configPath, "colour", "never")), "unknown settings should be config errors")
+	assert.Equal(t, KindConfig, KindOf(SetConfigValue(configPath, "parallelism", "0")), "invalid values should be config errors")
+}
+
+func TestLoadConfigValidatesEnvironment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bunch-config")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	os.Setenv("BUNCH_MIRROR", "github.com/*")
+	defer os.Unsetenv("BUNCH_MIRROR")
+
+	_, err = LoadConfig(dir)
+	assert.NotNil(t, err, "invalid settings from the environment should be rejected")
+	assert.Equal(t, KindConfig, KindOf(err), "invalid settings should be config errors")
+}
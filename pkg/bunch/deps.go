@@ -0,0 +1,565 @@
+package bunch
+
+import (
+	"fmt"
+	"go/build"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	version "github.com/hashicorp/go-version"
+	"github.com/juju/errors"
+)
+
+type packageImport struct {
+	Path     string
+	Importer string // directory of the importing package, used for vendor/ lookups
+}
+
+type DependencyConflict struct {
+	Import   string
+	Importer string
+	Root     string
+	Revision string
+	Pinned   string // version constraint from the Bunchfile, if any
+}
+
+func (c DependencyConflict) String() string {
+	description := fmt.Sprintf("%s imports %s, but %s (at %s) doesn't contain it", c.Importer, c.Import, c.Root, orDefault(ShortCommit(c.Revision), "unknown revision"))
+
+	if c.Pinned != "" {
+		description += fmt.Sprintf("; it is pinned to %s in the Bunchfile", c.Pinned)
+	}
+
+	return description
+}
+
+func isStandardImport(importPath string) bool { // like the go tool, paths without a dot in the first element are left to GOROOT
+	return !strings.Contains(strings.Split(importPath, "/")[0], ".")
+}
+
+func importsOf(ctx build.Context, dir string) []packageImport { // non-standard imports of the package in dir
+	imports := []packageImport{}
+
+	pkg, err := ctx.ImportDir(dir, 0)
+	if err != nil {
+		return imports // no buildable go files for this platform, or not a valid package
+	}
+
+	for _, importPath := range pkg.Imports {
+		if importPath == "C" || build.IsLocalImport(importPath) || isStandardImport(importPath) {
+			continue
+		}
+
+		imports = append(imports, packageImport{Path: importPath, Importer: dir})
+	}
+
+	return imports
+}
+
+func (e *engine) scanPackageImports(dir string) []packageImport { // imports of the package in dir only
+	ctx := build.Default
+	ctx.GOPATH = e.gopath
+
+	return importsOf(ctx, dir)
+}
+
+func (e *engine) scanImports(dir string) ([]packageImport, error) { // imports of every package under dir, like ./...
+	ctx := build.Default
+	ctx.GOPATH = e.gopath
+
+	imports := []packageImport{}
+
+	err := filepath.Walk(dir, func(walkPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		name := info.Name()
+		if walkPath != dir && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor") {
+			return filepath.SkipDir
+		}
+
+		imports = append(imports, importsOf(ctx, walkPath)...)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return imports, nil
+}
+
+func (e *engine) importSatisfied(imp packageImport) bool {
+	return e.importDir(imp) != ""
+}
+
+func (e *engine) importDir(imp packageImport) string { // checks vendor/ directories above the importer, then GOPATH; "" if missing
+	srcDir := path.Join(e.gopath, "src")
+
+	for dir := imp.Importer; strings.HasPrefix(dir, srcDir+"/"); dir = path.Dir(dir) {
+		if exists, _ := pathExists(path.Join(dir, "vendor", imp.Path)); exists {
+			return path.Join(dir, "vendor", imp.Path)
+		}
+	}
+
+	if exists, _ := pathExists(path.Join(srcDir, imp.Path)); exists {
+		return path.Join(srcDir, imp.Path)
+	}
+
+	return ""
+}
+
+func findPin(pins []Package, repoRoot string) (Package, bool) {
+	for _, pin := range pins {
+		pinPath := getRealRepoPath(pin.Repo)
+		if !pin.IsLink && (pinPath == repoRoot || strings.HasPrefix(pinPath, repoRoot+"/")) {
+			return pin, true
+		}
+	}
+
+	return Package{}, false
+}
+
+// Requirement is a version some Bunchfile asks for; Source is the repository whose Bunchfile it
+// came from
+type Requirement struct {
+	Package Package
+	Source  string
+}
+
+func (r Requirement) String() string {
+	return fmt.Sprintf("%s by %s", orDefault(r.Package.Version, "any version"), r.Source)
+}
+
+type dependencyFetcher struct {
+	*engine
+
+	pins          []Package
+	respectLocked bool
+
+	requirements map[string][]Requirement // by repository root, from the Bunchfiles of dependencies
+	readRoots    map[string]bool
+	clonedRoots  map[string]bool // everything cloned during this install, free to be moved again
+
+	missing   []DependencyConflict
+	conflicts []*ResolutionConflict
+	seenPaths map[string]bool
+}
+
+func (e *engine) newDependencyFetcher(pins []Package, respectLocked bool) *dependencyFetcher {
+	return &dependencyFetcher{
+		engine:        e,
+		pins:          pins,
+		respectLocked: respectLocked,
+		requirements:  map[string][]Requirement{},
+		readRoots:     map[string]bool{},
+		clonedRoots:   map[string]bool{},
+		missing:       []DependencyConflict{},
+		conflicts:     []*ResolutionConflict{},
+		seenPaths:     map[string]bool{},
+	}
+}
+
+// fetchPackageDependencies clones the repositories holding imports of repo that aren't in the vendor
+// tree yet, and then the imports of the imported packages in turn (but not of the rest of those
+// repositories, like go get). Repositories that are already present are never updated.
+// Versions come from the project's Bunchfile or lock file first, then from the Bunchfiles of the
+// dependencies that need them; fetcher carries those across the packages of one install.
+func (e *engine) fetchPackageDependencies(repo string, fetcher *dependencyFetcher) error {
+	packageDir := path.Join(e.gopath, "src", getRealRepoPath(repo))
+
+	step := Step{Action: "fetching dependencies for", Subject: repo, Verbose: true}
+	e.reporter.Begin(step)
+
+	fetcher.reset()
+	err := fetcher.fetch(packageDir)
+
+	if err != nil {
+		e.reporter.End(step, StepFailed, "")
+		return errors.Annotatef(err, "failed fetching dependencies for package %s", repo)
+	}
+
+	descriptions := []string{}
+	for _, conflict := range fetcher.missing {
+		descriptions = append(descriptions, "  "+conflict.String())
+	}
+	for _, conflict := range fetcher.conflicts {
+		descriptions = append(descriptions, "  "+conflict.String())
+	}
+
+	if len(descriptions) > 0 {
+		e.reporter.End(step, StepFailed, "failed, conflicting versions")
+		return newError(KindConstraint, "conflicting dependencies for package %s:\n%s", repo, strings.Join(descriptions, "\n"))
+	}
+
+	e.reporter.End(step, StepDone, "")
+
+	return nil
+}
+
+func (f *dependencyFetcher) reset() { // forgets the conflicts of the previous package, but not requirements
+	f.missing = []DependencyConflict{}
+	f.conflicts = []*ResolutionConflict{}
+	f.seenPaths = map[string]bool{}
+}
+
+func (f *dependencyFetcher) fetch(dir string) error {
+	srcDir := path.Join(f.gopath, "src")
+
+	rootDir, err := f.getPackageRootDir(strings.TrimPrefix(dir, srcDir+"/"))
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = f.readNestedBunchfile(rootDir)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	queue, err := f.scanImports(dir)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	scanned := map[string]bool{}
+
+	for len(queue) > 0 {
+		imp := queue[0]
+		queue = queue[1:]
+
+		if found := f.importDir(imp); found != "" {
+			// packages from repositories cloned for this install need their imports too
+			if found == path.Join(srcDir, imp.Path) && !scanned[found] && f.clonedFor(imp.Path) {
+				scanned[found] = true
+				queue = append(queue, f.scanPackageImports(found)...)
+			}
+			continue
+		}
+
+		repoRoot, err := f.resolveRepoRoot(imp.Path)
+		if err != nil {
+			return newError(KindFetch, "failed finding repository for %s: %s", imp.Path, err)
+		}
+
+		rootDir := path.Join(srcDir, repoRoot.Root)
+
+		if exists, _ := pathExists(rootDir); exists {
+			// the repository is there but at a revision without this package; updating it
+			// could break whatever else pinned it, so leave the decision to the user
+			if !f.seenPaths[imp.Path] {
+				f.seenPaths[imp.Path] = true
+
+				revision, _ := f.getRevisionAt(rootDir)
+				importer, _ := filepath.Rel(srcDir, imp.Importer)
+
+				conflict := DependencyConflict{Import: imp.Path, Importer: importer, Root: repoRoot.Root, Revision: revision}
+				if pin, ok := findPin(f.pins, repoRoot.Root); ok {
+					conflict.Pinned = pin.Version
+				}
+
+				f.missing = append(f.missing, conflict)
+			}
+			continue
+		}
+
+		step := Step{Action: "fetching dependency", Subject: repoRoot.Root, Verbose: true}
+		f.reporter.Begin(step)
+
+		err = f.cloneRepo(repoRoot)
+		f.reportStep(step, err)
+
+		if err != nil {
+			return newError(KindFetch, "failed cloning %s: %s", repoRoot.Root, err)
+		}
+
+		f.clonedRoots[repoRoot.Root] = true
+
+		err = f.checkoutRequiredVersion(repoRoot.Root)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		err = f.readNestedBunchfile(rootDir)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		queue = append(queue, imp) // again, now that its repository is there
+	}
+
+	sort.Slice(f.missing, func(i, j int) bool { return f.missing[i].Import < f.missing[j].Import })
+	sort.Slice(f.conflicts, func(i, j int) bool { return f.conflicts[i].Root < f.conflicts[j].Root })
+
+	return nil
+}
+
+func (f *dependencyFetcher) clonedFor(importPath string) bool { // whether importPath is in a repository cloned by this install
+	for root := range f.clonedRoots {
+		if importPath == root || strings.HasPrefix(importPath, root+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requirementsFor lists what the project's Bunchfile and the Bunchfiles of dependencies ask of root,
+// the project's own entry first
+func (f *dependencyFetcher) requirementsFor(root string) []Requirement {
+	requirements := []Requirement{}
+
+	if pin, ok := findPin(f.pins, root); ok {
+		requirements = append(requirements, Requirement{Package: pin, Source: "Bunchfile"})
+	}
+
+	return append(requirements, f.requirements[root]...)
+}
+
+// resolveTarget picks the revision root should be checked out at, returning the requirement to
+// describe it by. The project's lock file is authoritative; a single requirement is resolved the
+// usual way (honoring branches and lock files), several are resolved together.
+func (f *dependencyFetcher) resolveTarget(root string) (Package, string, error) {
+	if pin, ok := findPin(f.pins, root); ok && f.respectLocked && pin.LockedVersion != "" {
+		return pin, pin.LockedVersion, f.verifyLockedRevision(pin)
+	}
+
+	requirements := f.requirementsFor(root)
+
+	if len(requirements) == 0 {
+		return Package{}, "", nil
+	}
+
+	pack := requirements[0].Package
+
+	if len(requirements) == 1 {
+		target, err := f.resolvePackageTarget(pack, f.respectLocked)
+		return pack, target, err
+	}
+
+	if exists, _ := pathExists(path.Join(f.gopath, "src", root, ".git")); !exists {
+		target, err := f.resolvePackageTarget(pack, f.respectLocked) // only git repositories can be resolved together
+		return pack, target, err
+	}
+
+	target, err := f.resolveRequirements(root, requirements)
+	if err != nil {
+		return pack, "", err
+	}
+
+	if target == "" { // nobody asked for a particular version
+		target, err = f.resolvePackageTarget(pack, false)
+		return pack, target, err
+	}
+
+	humanVersions := []string{}
+	for _, requirement := range requirements {
+		if requirement.Package.Version != "" && !stringInSlice(requirement.Package.Version, humanVersions) {
+			humanVersions = append(humanVersions, requirement.Package.Version)
+		}
+	}
+
+	pack.Version = strings.Join(humanVersions, ", ")
+
+	return pack, target, nil
+}
+
+func (f *dependencyFetcher) checkoutRequiredVersion(root string) error {
+	pack, target, err := f.resolveTarget(root)
+	if conflict, ok := err.(*ResolutionConflict); ok {
+		f.recordConflict(conflict)
+		return nil
+	} else if err != nil {
+		return errors.Trace(err)
+	}
+
+	return f.setPackageVersion(pack.Repo, target, pack.Version)
+}
+
+func (f *dependencyFetcher) recordConflict(conflict *ResolutionConflict) { // replaces an earlier conflict on the same root
+	for i := range f.conflicts {
+		if f.conflicts[i].Root == conflict.Root {
+			f.conflicts[i] = conflict
+			return
+		}
+	}
+
+	f.conflicts = append(f.conflicts, conflict)
+}
+
+// packageTarget is resolvePackageTarget for packages being installed, taking into account what
+// their dependents asked for
+func (f *dependencyFetcher) packageTarget(pack Package) (string, error) {
+	if !pack.IsLink {
+		for root := range f.requirements {
+			if repo := getRealRepoPath(pack.Repo); repo == root || strings.HasPrefix(repo, root+"/") {
+				_, target, err := f.resolveTarget(root)
+				return target, err
+			}
+		}
+	}
+
+	return f.resolvePackageTarget(pack, f.respectLocked)
+}
+
+// readNestedBunchfile records what a vendored repository's own Bunchfile asks for
+func (f *dependencyFetcher) readNestedBunchfile(rootDir string) error {
+	if f.readRoots[rootDir] {
+		return nil
+	}
+
+	f.readRoots[rootDir] = true
+
+	filename := path.Join(rootDir, "Bunchfile")
+	if exists, _ := pathExists(filename); !exists {
+		return nil
+	}
+
+	nested, err := readBunchfileAt(filename, path.Join(rootDir, "Bunchfile.lock"), rootDir)
+	if err != nil {
+		return errors.Annotatef(err, "failed reading %s", filename)
+	}
+
+	source, _ := filepath.Rel(path.Join(f.gopath, "src"), rootDir)
+
+	for _, pack := range nested.Packages {
+		if pack.IsLink {
+			continue
+		}
+
+		repoRoot, err := f.resolveRepoRoot(pack.Repo)
+		if err != nil {
+			return errors.Annotatef(err, "failed finding repository for %s, required by %s", pack.Repo, source)
+		}
+
+		f.requirements[repoRoot.Root] = append(f.requirements[repoRoot.Root], Requirement{Package: pack, Source: source})
+
+		if exists, _ := pathExists(path.Join(f.gopath, "src", repoRoot.Root)); !exists {
+			continue
+		}
+
+		if f.clonedRoots[repoRoot.Root] {
+			err = f.checkoutRequiredVersion(repoRoot.Root) // we picked its revision, so we can pick again
+		} else if _, pinned := findPin(f.pins, repoRoot.Root); !pinned {
+			err = f.checkRequirements(repoRoot.Root)
+		} // pinned packages are resolved when they're installed
+
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	return nil
+}
+
+// checkRequirements records a conflict naming the vendored revision of root if it doesn't satisfy
+// every requirement on it. Lock files of dependencies are only used to pick a revision when cloning,
+// two dependents can't be expected to have locked the same commit.
+func (f *dependencyFetcher) checkRequirements(root string) error {
+	rootDir := path.Join(f.gopath, "src", root)
+	requirements := f.requirements[root]
+
+	satisfiedAll := true
+
+	for _, requirement := range requirements {
+		satisfied, err := f.revisionSatisfies(rootDir, requirement.Package.Version)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		satisfiedAll = satisfiedAll && satisfied
+	}
+
+	if satisfiedAll {
+		return nil
+	}
+
+	revision, err := f.getRevisionAt(rootDir)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	conflict, err := f.newResolutionConflict(root, revision, requirements)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	f.recordConflict(conflict)
+
+	return nil
+}
+
+// revisionSatisfies checks whether the checkout in repoDir matches a Bunchfile version: a tag
+// constraint is satisfied by a matching tag on HEAD, anything else has to resolve to HEAD
+func (e *engine) revisionSatisfies(repoDir string, versionPattern string) (bool, error) {
+	if versionPattern == "" {
+		return true, nil
+	}
+
+	if exists, _ := pathExists(path.Join(repoDir, ".git")); !exists {
+		return true, nil // only git checkouts can be compared
+	}
+
+	revision, err := e.getRevisionAt(repoDir)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+
+	if output, err := e.commandOutput(e.command(repoDir, "git", "rev-parse", "-q", "--verify", versionPattern+"^{commit}")); err == nil {
+		return strings.TrimSpace(string(output)) == revision, nil
+	}
+
+	constraints, err := version.NewConstraint(versionPattern)
+	if err != nil {
+		return false, nil
+	}
+
+	output, err := e.commandOutput(e.command(repoDir, "git", "tag", "--points-at", "HEAD"))
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+
+	versions, _ := parseVersionTags(strings.Split(strings.TrimSpace(string(output)), "\n"))
+	for _, v := range versions {
+		if constraints.Check(v) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// dependencyPins are the packages whose versions transitive dependencies must respect: the ones
+// being installed plus, for vendored installs, everything in the Bunchfile
+func (e *engine) dependencyPins(packages []Package, installGlobally bool) []Package {
+	pins := append([]Package{}, packages...)
+
+	if installGlobally {
+		return pins
+	}
+
+	if exists, _ := pathExists(e.bunchfilePath()); !exists {
+		return pins
+	}
+
+	b, err := e.readBunchfile()
+	if err != nil {
+		return pins
+	}
+
+	for _, pack := range b.Packages {
+		if _, ok := findPin(pins, getRealRepoPath(pack.Repo)); !ok {
+			pins = append(pins, pack)
+		}
+	}
+
+	return pins
+}
@@ -0,0 +1,166 @@
+package bunch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeGoFile(t *testing.T, filename string, contents string) {
+	assert.Nil(t, os.MkdirAll(path.Dir(filename), 0755), "package dir should be created")
+	assert.Nil(t, ioutil.WriteFile(filename, []byte(contents), 0644), "go file should be written")
+}
+
+func testEngine() *engine {
+	return (&Project{Quiet: true}).newEngine(context.Background())
+}
+
+func withTempGopath(t *testing.T) (*engine, func()) { // an engine with a temp dir as its vendor GOPATH
+	dir, err := ioutil.TempDir("", "bunch-deps")
+	assert.Nil(t, err, "temp dir should be created")
+
+	e := (&Project{Root: dir, VendorDir: dir, Quiet: true}).newEngine(context.Background())
+	assert.Nil(t, e.useVendor(), "vendor env should be set up")
+
+	return e, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestScanImports(t *testing.T) {
+	e, cleanup := withTempGopath(t)
+	defer cleanup()
+
+	src := path.Join(e.gopath, "src")
+	writeGoFile(t, path.Join(src, "github.com/acme/app/app.go"), "package app\n\nimport (\n\t\"fmt\"\n\t\"github.com/acme/dep\"\n)\n\nvar _ = fmt.Sprint(dep.A)\n")
+	writeGoFile(t, path.Join(src, "github.com/acme/app/sub/sub.go"), "package sub\n\nimport \"github.com/acme/other/pkg\"\n\nvar _ = pkg.B\n")
+	writeGoFile(t, path.Join(src, "github.com/acme/app/testdata/bad.go"), "package bad\n\nimport \"github.com/acme/ignored\"\n")
+	writeGoFile(t, path.Join(src, "github.com/acme/app/vendor/github.com/acme/vendored/v.go"), "package vendored\n\nimport \"github.com/acme/ignored\"\n")
+
+	imports, err := e.scanImports(path.Join(src, "github.com/acme/app"))
+	assert.Nil(t, err, "scan should succeed")
+
+	importPaths := []string{}
+	for _, imp := range imports {
+		importPaths = append(importPaths, imp.Path)
+	}
+
+	assert.Equal(t, []string{"github.com/acme/dep", "github.com/acme/other/pkg"}, importPaths, "standard, testdata and vendored imports should be skipped")
+}
+
+func TestImportSatisfied(t *testing.T) {
+	e, cleanup := withTempGopath(t)
+	defer cleanup()
+
+	src := path.Join(e.gopath, "src")
+	importer := path.Join(src, "github.com/acme/app/sub")
+
+	_ = os.MkdirAll(path.Join(src, "github.com/acme/app/vendor/github.com/acme/vendored"), 0755)
+	_ = os.MkdirAll(path.Join(src, "github.com/acme/dep"), 0755)
+
+	assert.True(t, e.importSatisfied(packageImport{Path: "github.com/acme/vendored", Importer: importer}), "vendor dirs above the importer should count")
+	assert.True(t, e.importSatisfied(packageImport{Path: "github.com/acme/dep", Importer: importer}), "packages in GOPATH should count")
+	assert.False(t, e.importSatisfied(packageImport{Path: "github.com/acme/missing", Importer: importer}), "missing packages should not count")
+}
+
+func TestDependencyFetcherReportsMissingPackages(t *testing.T) {
+	e, cleanup := withTempGopath(t)
+	defer cleanup()
+
+	src := path.Join(e.gopath, "src")
+	writeGoFile(t, path.Join(src, "github.com/acme/app/app.go"), "package app\n\nimport \"github.com/acme/dep/extra\"\n\nvar _ = extra.B\n")
+	writeGoFile(t, path.Join(src, "github.com/acme/dep/dep.go"), "package dep\n")
+
+	pins := []Package{{Repo: "github.com/acme/dep", Version: "~> 1.0"}}
+
+	fetcher := e.newDependencyFetcher(pins, true)
+	err := fetcher.fetch(path.Join(src, "github.com/acme/app"))
+	assert.Nil(t, err, "conflicts should be reported, not returned as errors")
+	assert.Empty(t, fetcher.clonedRoots, "nothing should be cloned")
+	assert.Equal(t, []DependencyConflict{{
+		Import:   "github.com/acme/dep/extra",
+		Importer: "github.com/acme/app",
+		Root:     "github.com/acme/dep",
+		Pinned:   "~> 1.0",
+	}}, fetcher.missing, "missing package in an existing repository should be a conflict")
+}
+
+func TestFindPin(t *testing.T) {
+	pins := []Package{
+		{Repo: "github.com/acme/dep/cmd/tool", Version: "~> 1.0"},
+		{Repo: "github.com/acme/linked", IsLink: true},
+	}
+
+	pin, ok := findPin(pins, "github.com/acme/dep")
+	assert.True(t, ok, "subpackages should pin their repository")
+	assert.Equal(t, "~> 1.0", pin.Version, "pin version should be returned")
+
+	_, ok = findPin(pins, "github.com/acme/linked")
+	assert.False(t, ok, "links aren't pins")
+
+	_, ok = findPin(pins, "github.com/acme/de")
+	assert.False(t, ok, "prefixes should only match whole path elements")
+}
+
+func TestReadNestedBunchfile(t *testing.T) {
+	e, cleanup := withTempGopath(t)
+	defer cleanup()
+
+	rootDir := path.Join(e.gopath, "src", "github.com/acme/lib")
+	writeGoFile(t, path.Join(rootDir, "Bunchfile"), "github.com/acme/dep/sub ~> 1.1\ngithub.com/acme/pinned 2.0.0\ngithub.com/acme/local !link:../local\n")
+	writeGoFile(t, path.Join(rootDir, "Bunchfile.lock"), `{"github.com/acme/dep/sub": "abc123"}`)
+
+	fetcher := e.newDependencyFetcher([]Package{{Repo: "github.com/acme/pinned", Version: "1.0.0"}}, true)
+	assert.Nil(t, fetcher.readNestedBunchfile(rootDir), "nested Bunchfile should be read")
+
+	assert.Equal(t, map[string][]Requirement{
+		"github.com/acme/dep": {{
+			Package: Package{Repo: "github.com/acme/dep/sub", Version: "~> 1.1", LockedVersion: "abc123"},
+			Source:  "github.com/acme/lib",
+		}},
+		"github.com/acme/pinned": {{
+			Package: Package{Repo: "github.com/acme/pinned", Version: "2.0.0"},
+			Source:  "github.com/acme/lib",
+		}},
+	}, fetcher.requirements, "requirements should be keyed by repository, skipping links")
+
+	assert.Equal(t, []Requirement{
+		{Package: Package{Repo: "github.com/acme/pinned", Version: "1.0.0"}, Source: "Bunchfile"},
+		{Package: Package{Repo: "github.com/acme/pinned", Version: "2.0.0"}, Source: "github.com/acme/lib"},
+	}, fetcher.requirementsFor("github.com/acme/pinned"), "the project's requirement should come first")
+}
+
+func TestDependencyFetcherOnlyFollowsImportedPackages(t *testing.T) {
+	e, cleanup := withTempGopath(t)
+	defer cleanup()
+
+	upstream, err := ioutil.TempDir("", "bunch-upstream")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(upstream)
+
+	// dep/used is imported and needs needed; dep/unused needs a repository that doesn't exist
+	writeGoFile(t, path.Join(upstream, "acme/dep/used/used.go"), "package used\n\nimport \"example.com/acme/needed\"\n\nvar _ = needed.A\n")
+	writeGoFile(t, path.Join(upstream, "acme/dep/unused/unused.go"), "package unused\n\nimport \"example.com/acme/unrelated\"\n\nvar _ = unrelated.A\n")
+	writeGoFile(t, path.Join(upstream, "acme/needed/needed.go"), "package needed\n\nvar A = 1\n")
+
+	for _, repo := range []string{"acme/dep", "acme/needed"} {
+		gitIn(t, path.Join(upstream, repo), "init", "-q")
+		gitIn(t, path.Join(upstream, repo), "add", ".")
+		gitIn(t, path.Join(upstream, repo), "commit", "-q", "-m", "first")
+	}
+
+	e.mirrorRules = []MirrorRule{{Pattern: "example.com/*", URL: upstream + "/*"}}
+
+	src := path.Join(e.gopath, "src")
+	writeGoFile(t, path.Join(src, "example.com/acme/app/app.go"), "package app\n\nimport \"example.com/acme/dep/used\"\n\nvar _ = used.A\n")
+
+	fetcher := e.newDependencyFetcher(nil, true)
+	err = fetcher.fetch(path.Join(src, "example.com/acme/app"))
+	assert.Nil(t, err, "dependencies should be fetched")
+	assert.Empty(t, fetcher.missing, "nothing should be missing")
+	assert.Equal(t, map[string]bool{"example.com/acme/dep": true, "example.com/acme/needed": true}, fetcher.clonedRoots, "only the imports of imported packages should be cloned")
+}
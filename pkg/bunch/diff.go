@@ -0,0 +1,155 @@
+package bunch
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+func (e *engine) getDiffstat(repoDir string, fromRev string, toRev string) ([]string, error) {
+	output, err := e.commandOutput(e.command(repoDir, "git", "diff", "--stat", fromRev, toRev))
+	if err != nil {
+		return nil, errors.Annotatef(err, "failed reading diff between %s and %s", fromRev, toRev)
+	}
+
+	diffstat := []string{}
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		if strings.TrimSpace(line) != "" {
+			diffstat = append(diffstat, line)
+		}
+	}
+
+	return diffstat, nil
+}
+
+func (e *engine) resolveGitRev(repoDir string, rev string) (string, error) {
+	output, err := e.commandOutput(e.command(repoDir, "git", "rev-parse", "-q", "--verify", rev+"^{commit}"))
+	if err != nil {
+		return "", fmt.Errorf("unknown revision %s", rev)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (e *engine) gitPackageDir(repo string) (string, error) {
+	packageDir, err := e.getPackageRootDir(getRealRepoPath(repo))
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	if exists, _ := pathExists(path.Join(packageDir, ".git")); !exists {
+		return "", fmt.Errorf("package %s is not an installed git repository, run 'bunch install' first", repo)
+	}
+
+	return packageDir, nil
+}
+
+func (e *engine) diffPackage(pack Package, fromRev string, toRev string) (*PackageDiff, error) {
+	err := e.useVendor()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	// only installed packages are refreshed; diff doesn't clone anything
+	packageDir, err := e.gitPackageDir(pack.Repo)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	err = e.fetchPackage(pack.Repo)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if toRev == "" {
+		toRev, err = e.resolvePackageTarget(pack, true)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	if fromRev == "" {
+		fromRev = "HEAD"
+	}
+
+	diff := &PackageDiff{Repo: pack.Repo, From: fromRev, To: toRev, Commits: []string{}, RolledBack: []string{}, Diffstat: []string{}}
+
+	diff.FromCommit, err = e.resolveGitRev(packageDir, fromRev)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	diff.ToCommit, err = e.resolveGitRev(packageDir, toRev)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if diff.FromCommit == diff.ToCommit {
+		return diff, nil
+	}
+
+	diff.Commits, err = e.getChangelog(packageDir, diff.FromCommit, diff.ToCommit)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	// a target behind the installed revision shows up as commits that would be dropped
+	diff.RolledBack, err = e.getChangelog(packageDir, diff.ToCommit, diff.FromCommit)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	diff.Diffstat, err = e.getDiffstat(packageDir, diff.FromCommit, diff.ToCommit)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return diff, nil
+}
+
+func (e *engine) previewPackageUpdate(pack Package, target string) (bool, error) {
+	packageDir, err := e.gitPackageDir(pack.Repo)
+	if err != nil {
+		e.reporter.Message(MessageWarning, fmt.Sprintf("package %s ... skipped, preview is only supported for git packages", pack.Repo))
+		return false, nil
+	}
+
+	installedCommit, err := e.resolveGitRev(packageDir, "HEAD")
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+
+	targetCommit, err := e.resolveGitRev(packageDir, target)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+
+	if installedCommit == targetCommit {
+		if e.verbose {
+			e.reporter.Message(MessageSuccess, fmt.Sprintf("package %s ... up to date", pack.Repo))
+		}
+		return false, nil
+	}
+
+	changelog, err := e.getChangelog(packageDir, installedCommit, targetCommit)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+
+	diffstat, err := e.getDiffstat(packageDir, installedCommit, targetCommit)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+
+	summary := CountCommits(len(changelog))
+	if len(diffstat) > 0 {
+		summary = fmt.Sprintf("%s,%s", summary, strings.SplitN(diffstat[len(diffstat)-1], ",", 2)[0])
+	}
+
+	e.reporter.Message(MessageWarning, fmt.Sprintf("package %s ... would update %s -> %s (%s)", pack.Repo, ShortCommit(installedCommit), ShortCommit(targetCommit), strings.TrimSpace(summary)))
+	e.reportChangelog(changelog)
+
+	return true, nil
+}
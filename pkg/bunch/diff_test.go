@@ -0,0 +1,54 @@
+package bunch
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffPackage(t *testing.T) {
+	e, cleanup := withTempGopath(t)
+	defer cleanup()
+
+	_, repoDir := cloneTaggedRepo(t, e, "example.com/a/lib", "v1.0.0", "v1.1.0", "v1.2.0")
+	gitIn(t, repoDir, "checkout", "-q", "v1.1.0")
+
+	pack := Package{Repo: "example.com/a/lib"}
+
+	diff, err := e.diffPackage(pack, "", "v1.2.0")
+	assert.Nil(t, err, "diff should succeed")
+	assert.Equal(t, gitIn(t, repoDir, "rev-parse", "v1.1.0"), diff.FromCommit, "diff should start at the checkout")
+	assert.Equal(t, gitIn(t, repoDir, "rev-parse", "v1.2.0"), diff.ToCommit, "diff should end at the target")
+	assert.Equal(t, 1, len(diff.Commits), "newer commits should be listed")
+	assert.Contains(t, diff.Commits[0], "release v1.2.0", "commits should show their subject")
+	assert.Empty(t, diff.RolledBack, "nothing should be rolled back")
+	assert.Contains(t, strings.Join(diff.Diffstat, "\n"), "VERSION", "diffstat should name the changed files")
+
+	diff, err = e.diffPackage(pack, "", "v1.0.0")
+	assert.Nil(t, err, "diff should succeed")
+	assert.Empty(t, diff.Commits, "an older target has no new commits")
+	assert.Equal(t, 1, len(diff.RolledBack), "commits after an older target should be rolled back")
+	assert.Contains(t, diff.RolledBack[0], "release v1.1.0", "rolled back commits should show their subject")
+
+	diff, err = e.diffPackage(pack, "v1.0.0", "v1.0.0")
+	assert.Nil(t, err, "diff should succeed")
+	assert.Empty(t, diff.Commits, "the same revision has no commits")
+	assert.Empty(t, diff.Diffstat, "the same revision has no diffstat")
+}
+
+func TestDiffPackageNotInstalled(t *testing.T) {
+	e, cleanup := withTempGopath(t)
+	defer cleanup()
+
+	_, repoDir := cloneTaggedRepo(t, e, "example.com/a/lib", "v1.0.0")
+	_ = os.RemoveAll(repoDir)
+
+	_, err := e.diffPackage(Package{Repo: "example.com/a/lib"}, "", "")
+	assert.NotNil(t, err, "uninstalled packages can't be diffed")
+	assert.Contains(t, err.Error(), "bunch install", "error should say how to install it")
+
+	exists, _ := pathExists(repoDir)
+	assert.False(t, exists, "diff shouldn't clone packages that aren't installed")
+}
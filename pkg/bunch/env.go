@@ -0,0 +1,111 @@
+package bunch
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+func findProjectRoot(start string) (string, bool) { // walks upward from start looking for a Bunchfile
+	dir := start
+
+	for {
+		if exists, _ := pathExists(path.Join(dir, "Bunchfile")); exists {
+			return dir, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return start, false
+		}
+
+		dir = parent
+	}
+}
+
+func resolveVendorDir(root string, configured string) string {
+	if configured == "" {
+		configured = ".vendor"
+	}
+
+	if filepath.IsAbs(configured) {
+		return filepath.Clean(configured)
+	}
+
+	return path.Join(root, configured)
+}
+
+func (e *engine) bunchfilePath() string {
+	return path.Join(e.projectRoot, "Bunchfile")
+}
+
+func (e *engine) bunchfileLockPath() string {
+	return path.Join(e.projectRoot, "Bunchfile.lock")
+}
+
+func sameEnvName(a string, b string) bool { // variable names are case-insensitive on Windows (Path, PATH)
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+
+	return a == b
+}
+
+func lookupEnv(env []string, name string) (string, bool) { // the value of name in env, the last one if it's set twice
+	value, found := "", false
+
+	for _, entry := range env {
+		if parts := strings.SplitN(entry, "=", 2); len(parts) == 2 && sameEnvName(parts[0], name) {
+			value, found = parts[1], true
+		}
+	}
+
+	return value, found
+}
+
+// setEnv returns a copy of env with entries (NAME=value) added, replacing earlier values of the
+// same variables
+func setEnv(env []string, entries ...string) []string {
+	result := []string{}
+
+	for _, entry := range env {
+		name := strings.SplitN(entry, "=", 2)[0]
+
+		replaced := false
+		for _, newEntry := range entries {
+			replaced = replaced || sameEnvName(name, strings.SplitN(newEntry, "=", 2)[0])
+		}
+
+		if !replaced {
+			result = append(result, entry)
+		}
+	}
+
+	return append(result, entries...)
+}
+
+// useVendor points the operation at the vendor dir: packages are read from and installed into
+// it, and commands run with it as GOPATH and its bin dir first on PATH, behind the Bunchfile's
+// Go version if that is installed. It fails if the go found that way isn't the version asked for.
+func (e *engine) useVendor() error {
+	err := e.resolveGoToolchain()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	newPath := fmt.Sprintf("%s%c%s", path.Join(e.vendorDir, "bin"), os.PathListSeparator, e.initialPath)
+
+	if e.goToolchain.bin != "" {
+		newPath = fmt.Sprintf("%s%c%s", e.goToolchain.bin, os.PathListSeparator, newPath)
+	}
+
+	e.gopath = e.vendorDir
+	e.env = setEnv(e.userEnv, "GOPATH="+e.vendorDir, "PATH="+newPath)
+
+	return e.checkGoVersion()
+}
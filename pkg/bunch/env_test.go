@@ -0,0 +1,35 @@
+package bunch
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindProjectRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bunch-root")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	subdir := path.Join(dir, "cmd", "tool")
+	_ = os.MkdirAll(subdir, 0755)
+
+	root, found := findProjectRoot(subdir)
+	assert.False(t, found, "no Bunchfile should be found yet")
+	assert.Equal(t, subdir, root, "root should fall back to the starting dir")
+
+	_ = ioutil.WriteFile(path.Join(dir, "Bunchfile"), []byte("github.com/a/b\n"), 0644)
+
+	root, found = findProjectRoot(subdir)
+	assert.True(t, found, "Bunchfile should be found")
+	assert.Equal(t, dir, root, "root should be the dir containing the Bunchfile")
+}
+
+func TestResolveVendorDir(t *testing.T) {
+	assert.Equal(t, "/src/app/.vendor", resolveVendorDir("/src/app", ""), "vendor dir should default to .vendor")
+	assert.Equal(t, "/src/app/deps", resolveVendorDir("/src/app", "deps"), "relative vendor dirs should be relative to the root")
+	assert.Equal(t, "/var/cache/deps", resolveVendorDir("/src/app", "/var/cache/deps/"), "absolute vendor dirs should be used as-is")
+}
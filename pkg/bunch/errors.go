@@ -0,0 +1,63 @@
+package bunch
+
+import (
+	"github.com/juju/errors"
+)
+
+// ErrorKind classifies failures so callers can tell them apart without parsing messages
+type ErrorKind int
+
+const (
+	KindUnknown      ErrorKind = iota
+	KindBunchfile              // the Bunchfile or Bunchfile.lock is missing or can't be parsed
+	KindFetch                  // a repository couldn't be found, cloned or refreshed
+	KindConstraint             // no revision satisfies the requested versions
+	KindBuild                  // go build or go install failed for a package
+	KindLockMismatch           // Bunchfile.lock pins a revision the repository doesn't have
+	KindDirtyVendor            // a vendored repository has local changes that a checkout would clobber
+	KindUsage                  // bad arguments, e.g. an invalid target
+	KindConfig                 // a setting is unknown or has an invalid value
+)
+
+var kindNames = map[ErrorKind]string{
+	KindUnknown:      "unknown",
+	KindBunchfile:    "bunchfile",
+	KindFetch:        "fetch",
+	KindConstraint:   "constraint",
+	KindBuild:        "build",
+	KindLockMismatch: "lock mismatch",
+	KindDirtyVendor:  "dirty vendor",
+	KindUsage:        "usage",
+	KindConfig:       "config",
+}
+
+func (k ErrorKind) String() string {
+	return kindNames[k]
+}
+
+// Error is a failure of a known kind. It is the cause of the errors returned by Project methods,
+// so errors.Trace and errors.Annotate keep it reachable through errors.Cause.
+type Error struct {
+	errors.Err
+	Kind ErrorKind
+}
+
+var ErrNoBunchfile = newError(KindBunchfile, "no Bunchfile found")
+
+func newError(kind ErrorKind, format string, args ...interface{}) error {
+	err := &Error{Err: errors.NewErr(format, args...), Kind: kind}
+	err.SetLocation(1)
+	return err
+}
+
+// KindOf returns the kind of err, or KindUnknown if it wasn't classified
+func KindOf(err error) ErrorKind {
+	switch cause := errors.Cause(err).(type) {
+	case *Error:
+		return cause.Kind
+	case *ResolutionConflict:
+		return KindConstraint
+	}
+
+	return KindUnknown
+}
@@ -0,0 +1,20 @@
+package bunch
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKindOf(t *testing.T) {
+	err := newError(KindBuild, "failed building package %s", "github.com/a/b")
+
+	assert.Equal(t, KindBuild, KindOf(err), "kind should be read from the error")
+	assert.Equal(t, KindBuild, KindOf(errors.Trace(err)), "kind should survive errors.Trace")
+	assert.Equal(t, KindBuild, KindOf(errors.Annotate(errors.Trace(err), "vendor tree restored")), "kind should survive errors.Annotate")
+	assert.Equal(t, KindConstraint, KindOf(errors.Trace(&ResolutionConflict{Root: "github.com/a/b"})), "resolution conflicts should be constraint errors")
+	assert.Equal(t, KindUnknown, KindOf(fmt.Errorf("boom")), "other errors should be unknown")
+	assert.Equal(t, "failed building package github.com/a/b", err.Error(), "message should be formatted")
+}
@@ -0,0 +1,136 @@
+package bunch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+var lockPollInterval = 200 * time.Millisecond
+
+func (e *engine) vendorLockPath() string {
+	return path.Join(e.vendorDir, ".bunch.lock")
+}
+
+func readLockOwner(lockPath string) int {
+	contents, err := ioutil.ReadFile(lockPath)
+	if err != nil {
+		return 0
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+	if err != nil {
+		return 0
+	}
+
+	return pid
+}
+
+// acquireVendorLock takes the lock guarding the vendor dir, Bunchfile and Bunchfile.lock, waiting
+// up to lockTimeout for another bunch process to finish. It's a kernel lock on a file that is
+// never removed, so it goes away with the process holding it and there is nothing stale to take
+// over; the pid written into the file is only used to name the owner.
+func (e *engine) acquireVendorLock() (func(), error) {
+	lockPath := e.vendorLockPath()
+	deadline := time.Now().Add(e.lockTimeout)
+
+	lockFile, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.Annotatef(err, "unable to open lock file %s", lockPath)
+	}
+
+	for {
+		locked, err := tryLockFile(lockFile)
+		if err != nil {
+			_ = lockFile.Close()
+			return nil, errors.Annotatef(err, "unable to lock %s", lockPath)
+		}
+
+		if locked {
+			break
+		}
+
+		if !time.Now().Before(deadline) {
+			_ = lockFile.Close()
+
+			pid := readLockOwner(lockPath)
+			if pid == 0 {
+				return nil, fmt.Errorf("another bunch process is running (it holds %s); use --lock-timeout to wait for it", lockPath)
+			}
+
+			return nil, fmt.Errorf("another bunch process (pid %d) is running; use --lock-timeout to wait for it", pid)
+		}
+
+		select {
+		case <-e.ctx.Done():
+			_ = lockFile.Close()
+			return nil, errors.Trace(e.ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	err = lockFile.Truncate(0)
+	if err == nil {
+		_, err = lockFile.WriteAt([]byte(fmt.Sprintf("%d\n", os.Getpid())), 0)
+	}
+
+	if err != nil {
+		_ = unlockFile(lockFile)
+		_ = lockFile.Close()
+		return nil, errors.Trace(err)
+	}
+
+	return func() {
+		_ = lockFile.Truncate(0)
+		_ = unlockFile(lockFile)
+		_ = lockFile.Close()
+	}, nil
+}
+
+// writeFileAtomic writes to a temporary file next to filename and renames it into place,
+// so readers never see a partially written file
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+
+	tempFile, err := ioutil.TempFile(dir, fmt.Sprintf(".%s.tmp", base))
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	tempPath := tempFile.Name()
+
+	_, err = tempFile.Write(data)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+
+	closeErr := tempFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(tempPath, perm)
+	}
+
+	if err == nil {
+		err = os.Rename(tempPath, filename)
+	}
+
+	if err != nil {
+		_ = os.Remove(tempPath)
+		return errors.Trace(err)
+	}
+
+	return nil
+}
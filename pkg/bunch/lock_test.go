@@ -0,0 +1,118 @@
+package bunch
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func withTempVendor(t *testing.T, fn func(e *engine)) {
+	dir, err := ioutil.TempDir("", "bunch-lock")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	_ = os.MkdirAll(path.Join(dir, ".vendor"), 0755)
+
+	fn((&Project{Root: dir, VendorDir: path.Join(dir, ".vendor"), Quiet: true}).newEngine(context.Background()))
+}
+
+func TestAcquireVendorLock(t *testing.T) {
+	withTempVendor(t, func(e *engine) {
+		unlock, err := e.acquireVendorLock()
+		assert.Nil(t, err, "first lock should succeed")
+		assert.Equal(t, os.Getpid(), readLockOwner(e.vendorLockPath()), "lock file should name its owner")
+
+		_, err = e.acquireVendorLock()
+		assert.NotNil(t, err, "second lock should fail")
+		assert.Contains(t, err.Error(), fmt.Sprintf("pid %d", os.Getpid()), "error should name the owning pid")
+
+		unlock()
+
+		exists, _ := pathExists(e.vendorLockPath())
+		assert.True(t, exists, "unlocking should leave the lock file in place")
+
+		unlock, err = e.acquireVendorLock()
+		assert.Nil(t, err, "lock should be free again after unlocking")
+		unlock()
+	})
+}
+
+func TestAcquireVendorLockLeftBehind(t *testing.T) {
+	withTempVendor(t, func(e *engine) {
+		// a lock file left by a process that no longer holds the lock doesn't block anyone
+		err := ioutil.WriteFile(e.vendorLockPath(), []byte("999999999\n"), 0644)
+		assert.Nil(t, err, "old lock file should be written")
+
+		unlock, err := e.acquireVendorLock()
+		assert.Nil(t, err, "lock should be taken")
+		assert.Equal(t, os.Getpid(), readLockOwner(e.vendorLockPath()), "lock should now be owned by this process")
+
+		unlock()
+	})
+}
+
+func TestAcquireVendorLockWaits(t *testing.T) {
+	withTempVendor(t, func(e *engine) {
+		e.lockTimeout = 5 * time.Second
+
+		unlock, err := e.acquireVendorLock()
+		assert.Nil(t, err, "first lock should succeed")
+
+		go func() {
+			time.Sleep(2 * lockPollInterval)
+			unlock()
+		}()
+
+		unlock, err = e.acquireVendorLock()
+		assert.Nil(t, err, "lock should be taken once the first holder releases it")
+		unlock()
+	})
+}
+
+func TestDryRunsTakeVendorLock(t *testing.T) {
+	withTempVendor(t, func(e *engine) {
+		p := &Project{Root: e.projectRoot, VendorDir: e.vendorDir, DryRun: true, Quiet: true}
+
+		_, done, err := p.begin(context.Background(), true)
+		assert.Nil(t, err, "dry run should start")
+
+		_, err = e.acquireVendorLock()
+		assert.NotNil(t, err, "dry runs fetch into the vendor dir, so they should hold the lock")
+		done()
+
+		p.VendorDir = path.Join(e.projectRoot, "missing")
+
+		_, done, err = p.begin(context.Background(), true)
+		assert.Nil(t, err, "dry runs without a vendor dir have nothing to lock")
+		done()
+
+		exists, _ := pathExists(p.VendorDir)
+		assert.False(t, exists, "dry runs shouldn't create the vendor dir")
+	})
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	withTempVendor(t, func(e *engine) {
+		err := writeFileAtomic(e.bunchfilePath(), []byte("github.com/a/b\n"), 0644)
+		assert.Nil(t, err, "write should succeed")
+
+		contents, _ := ioutil.ReadFile(e.bunchfilePath())
+		assert.Equal(t, "github.com/a/b\n", string(contents), "contents should be written")
+
+		entries, _ := ioutil.ReadDir(e.projectRoot)
+		names := []string{}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		assert.Equal(t, []string{".vendor", "Bunchfile"}, names, "no temporary files should be left behind")
+
+		info, _ := os.Stat(e.bunchfilePath())
+		assert.Equal(t, os.FileMode(0644), info.Mode().Perm(), "permissions should be applied")
+	})
+}
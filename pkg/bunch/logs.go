@@ -0,0 +1,172 @@
+package bunch
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const keptRunLogs = 20 // older run logs are removed when a new one is created
+
+// commandLog records the output of every external command run during one operation in a file
+// under .vendor/.bunch/logs. The file is created on the first command, so operations that don't
+// run any leave nothing behind.
+type commandLog struct {
+	dir     string
+	root    string // the project directory, named in the log's header
+	path    string
+	file    *os.File
+	started time.Time
+	broken  bool // the log couldn't be created; commands still run, unlogged
+	mutex   sync.Mutex
+}
+
+func (e *engine) logsDir() string {
+	return path.Join(e.vendorDir, ".bunch", "logs")
+}
+
+func (e *engine) newCommandLog() *commandLog {
+	return &commandLog{dir: e.logsDir(), root: e.projectRoot, started: time.Now()}
+}
+
+func (l *commandLog) open() bool {
+	if l.file != nil || l.broken {
+		return !l.broken
+	}
+
+	l.broken = true
+
+	if exists, _ := pathExists(path.Dir(path.Dir(l.dir))); !exists { // no vendor dir to log into yet
+		return false
+	}
+
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return false
+	}
+
+	_ = pruneRunLogs(l.dir, keptRunLogs-1)
+
+	name := fmt.Sprintf("%s-%d.log", l.started.Format("20060102T150405"), os.Getpid())
+	file, err := os.OpenFile(path.Join(l.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return false
+	}
+
+	fmt.Fprintf(file, "# %s in %s, started %s\n", strings.Join(append([]string{filepath.Base(os.Args[0])}, os.Args[1:]...), " "), l.root, l.started.Format(time.RFC3339))
+
+	l.file = file
+	l.path = file.Name()
+	l.broken = false
+
+	return true
+}
+
+// record appends a finished command and its output as one entry, so output of commands run
+// in parallel doesn't interleave
+func (l *commandLog) record(command string, dir string, output []byte, err error, took time.Duration) {
+	if l == nil {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if !l.open() {
+		return
+	}
+
+	var entry bytes.Buffer
+
+	fmt.Fprintf(&entry, "\n$ %s\n# in %s\n", command, dir)
+	entry.Write(output)
+	if len(output) > 0 && output[len(output)-1] != '\n' {
+		entry.WriteByte('\n')
+	}
+
+	if err != nil {
+		fmt.Fprintf(&entry, "# failed after %s: %s\n", took.Round(time.Millisecond), err)
+	} else {
+		fmt.Fprintf(&entry, "# done in %s\n", took.Round(time.Millisecond))
+	}
+
+	_, _ = l.file.Write(entry.Bytes())
+}
+
+func (l *commandLog) logPath() string {
+	if l == nil {
+		return ""
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.path
+}
+
+func (l *commandLog) close() {
+	if l == nil {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.file != nil {
+		_ = l.file.Close()
+		l.file = nil
+	}
+}
+
+func listRunLogs(dir string) ([]string, error) { // oldest first
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	logs := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".log") {
+			logs = append(logs, path.Join(dir, entry.Name()))
+		}
+	}
+
+	sort.Strings(logs)
+
+	return logs, nil
+}
+
+func pruneRunLogs(dir string, keep int) error {
+	logs, err := listRunLogs(dir)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	for len(logs) > keep {
+		if err := os.Remove(logs[0]); err != nil {
+			return errors.Trace(err)
+		}
+		logs = logs[1:]
+	}
+
+	return nil
+}
+
+func tailLines(text string, count int) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if len(lines) > count {
+		lines = append([]string{fmt.Sprintf("(%d earlier lines omitted)", len(lines)-count)}, lines[len(lines)-count:]...)
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,72 @@
+package bunch
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCommandLogsOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bunch-logs")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	e := (&Project{Root: dir, VendorDir: dir, Quiet: true}).newEngine(context.Background())
+
+	e.runLog = e.newCommandLog()
+	defer e.runLog.close()
+
+	output, err := e.runCommand(e.command("", "sh", "-c", "echo fine"))
+	assert.Nil(t, err, "successful command should not fail")
+	assert.Equal(t, "fine\n", string(output), "output should be returned")
+
+	_, err = e.runCommand(e.command(dir, "sh", "-c", "for i in $(seq 1 30); do echo line $i; done; echo oops >&2; exit 3"))
+
+	failure, ok := err.(*commandError)
+	assert.True(t, ok, "failure should be a commandError")
+	assert.Equal(t, 3, failure.exitStatus, "exit status should be kept")
+
+	message := err.Error()
+	assert.Contains(t, message, "failed with exit status 3", "message should show the exit status")
+	assert.Contains(t, message, "in "+dir, "message should show the working directory")
+	assert.Contains(t, message, "(11 earlier lines omitted)", "message should only show the end of the output")
+	assert.Contains(t, message, "line 30", "message should show the end of the output")
+	assert.NotContains(t, message, "line 11\n", "message should leave out the start of the output")
+	assert.Contains(t, message, "full log: "+e.runLog.logPath(), "message should point at the log")
+
+	logs, err := listRunLogs(path.Join(dir, ".bunch", "logs"))
+	assert.Nil(t, err, "logs should be listed")
+	assert.Equal(t, 1, len(logs), "one log should be written per run")
+
+	contents, _ := ioutil.ReadFile(logs[0])
+	assert.Contains(t, string(contents), "$ sh -c echo fine\n", "log should record commands")
+	assert.Contains(t, string(contents), "line 1\n", "log should record the full output")
+	assert.Contains(t, string(contents), "oops\n", "log should record stderr")
+}
+
+func TestPruneRunLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bunch-logs")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	for i := 1; i <= 5; i++ {
+		_ = ioutil.WriteFile(path.Join(dir, fmt.Sprintf("2026010%dT000000-1.log", i)), []byte{}, 0644)
+	}
+
+	err = pruneRunLogs(dir, 2)
+	assert.Nil(t, err, "logs should be pruned")
+
+	logs, _ := listRunLogs(dir)
+	names := []string{}
+	for _, logPath := range logs {
+		names = append(names, path.Base(logPath))
+	}
+
+	assert.Equal(t, "20260104T000000-1.log 20260105T000000-1.log", strings.Join(names, " "), "newest logs should be kept")
+}
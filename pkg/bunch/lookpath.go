@@ -0,0 +1,89 @@
+package bunch
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// executableExtensions are the extensions tried after a command's name when looking it up: those
+// in PATHEXT on Windows, where files have no execute bit, and none elsewhere
+func executableExtensions() []string {
+	if runtime.GOOS != "windows" {
+		return nil
+	}
+
+	pathExt := os.Getenv("PATHEXT")
+	if pathExt == "" {
+		pathExt = ".com;.exe;.bat;.cmd"
+	}
+
+	exts := []string{}
+	for _, ext := range strings.Split(strings.ToLower(pathExt), ";") {
+		if ext != "" {
+			exts = append(exts, ext)
+		}
+	}
+
+	return exts
+}
+
+// findExecutable returns file, or file with one of exts added, if it is a program. Without exts,
+// a program is a regular file with an execute bit.
+func findExecutable(file string, exts []string) (string, bool) {
+	if exts == nil {
+		info, err := os.Stat(file)
+		return file, err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0
+	}
+
+	candidates := []string{}
+	if stringInSlice(strings.ToLower(filepath.Ext(file)), exts) {
+		candidates = append(candidates, file)
+	}
+	for _, ext := range exts {
+		candidates = append(candidates, file+ext)
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			return candidate, true
+		}
+	}
+
+	return "", false
+}
+
+func lookPathIn(name string, pathList string, exts []string) (string, error) {
+	if strings.ContainsAny(name, "/"+string(filepath.Separator)) {
+		if file, ok := findExecutable(name, exts); ok {
+			return file, nil
+		}
+
+		return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+
+	for _, dir := range filepath.SplitList(pathList) {
+		if dir == "" {
+			continue
+		}
+
+		if file, ok := findExecutable(filepath.Join(dir, name), exts); ok {
+			return file, nil
+		}
+	}
+
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+// LookPath is exec.LookPath searching the PATH in env (e.g. from VendorEnv) instead of the
+// process's, so the vendored binaries and the Bunchfile's Go version are found
+func LookPath(name string, env []string) (string, error) {
+	pathList, found := lookupEnv(env, "PATH")
+	if !found {
+		pathList = os.Getenv("PATH")
+	}
+
+	return lookPathIn(name, pathList, executableExtensions())
+}
@@ -0,0 +1,53 @@
+package bunch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookPathIn(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bunch-lookpath")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	first, second := filepath.Join(dir, "first"), filepath.Join(dir, "second")
+	_ = os.MkdirAll(first, 0755)
+	_ = os.MkdirAll(second, 0755)
+
+	_ = ioutil.WriteFile(filepath.Join(first, "tool"), []byte("not a program"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(second, "tool"), []byte("#!/bin/sh\n"), 0755)
+	pathList := fmt.Sprintf("%s%c%s", first, os.PathListSeparator, second)
+
+	found, err := lookPathIn("tool", pathList, nil)
+	assert.Nil(t, err, "tool should be found")
+	assert.Equal(t, filepath.Join(second, "tool"), found, "files without an execute bit should be skipped")
+
+	_, err = lookPathIn("missing", pathList, nil)
+	assert.NotNil(t, err, "missing programs should not be found")
+}
+
+func TestLookPathInWithExtensions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bunch-lookpath")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	// as on Windows: no execute bits, programs found through the PATHEXT extensions
+	_ = ioutil.WriteFile(filepath.Join(dir, "go.exe"), []byte("MZ"), 0644)
+	exts := []string{".com", ".exe", ".bat", ".cmd"}
+
+	found, err := lookPathIn("go", dir, exts)
+	assert.Nil(t, err, "go should be found as go.exe")
+	assert.Equal(t, filepath.Join(dir, "go.exe"), found, "extension should be added")
+
+	found, err = lookPathIn("go.exe", dir, exts)
+	assert.Nil(t, err, "go.exe should be found as it is")
+	assert.Equal(t, filepath.Join(dir, "go.exe"), found, "name with an extension should be kept")
+
+	_, err = lookPathIn("make", dir, exts)
+	assert.NotNil(t, err, "missing programs should not be found")
+}
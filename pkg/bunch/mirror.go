@@ -0,0 +1,120 @@
+package bunch
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// MirrorRule rewrites the clone URL of packages under Pattern. A trailing * in Pattern matches
+// the rest of the repository root and is substituted for the * in URL, e.g.
+// github.com/* -> https://git.internal/mirror/github.com/*
+type MirrorRule struct {
+	Pattern string
+	URL     string
+}
+
+func parseMirrorRules(value string) ([]MirrorRule, error) {
+	rules := []MirrorRule{}
+
+	for _, ruleString := range strings.Split(value, ",") {
+		ruleString = strings.TrimSpace(ruleString)
+		if ruleString == "" {
+			continue
+		}
+
+		parts := strings.SplitN(ruleString, "->", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%q is not a rule of the form pattern -> url", ruleString)
+		}
+
+		rule := MirrorRule{Pattern: strings.TrimSpace(parts[0]), URL: strings.TrimSpace(parts[1])}
+
+		if rule.Pattern == "" || rule.URL == "" {
+			return nil, fmt.Errorf("%q is not a rule of the form pattern -> url", ruleString)
+		}
+
+		if strings.Contains(strings.TrimSuffix(rule.Pattern, "*"), "*") {
+			return nil, fmt.Errorf("%q: * is only allowed at the end of a pattern", ruleString)
+		}
+
+		if strings.Count(rule.URL, "*") > 1 || (strings.Contains(rule.URL, "*") && !strings.HasSuffix(rule.Pattern, "*")) {
+			return nil, fmt.Errorf("%q: the url may only contain a * if the pattern ends with one", ruleString)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func validateMirrorRules(value string) error {
+	_, err := parseMirrorRules(value)
+	return err
+}
+
+func guessRepoRoot(repo string) string { // github.com/a/b/c -> github.com/a/b, or up to a .git/.hg/.bzr suffix
+	parts := strings.Split(repo, "/")
+
+	for i, part := range parts {
+		for _, suffix := range []string{".git", ".hg", ".bzr"} {
+			if strings.HasSuffix(part, suffix) {
+				return path.Join(parts[:i+1]...)
+			}
+		}
+	}
+
+	if len(parts) > 3 {
+		parts = parts[:3]
+	}
+
+	return path.Join(parts...)
+}
+
+// findMirror returns the repository root and mirror URL for repo, if a rule matches it. A rule
+// without a * names the repository root itself, which helps with hosts that don't follow
+// the host/owner/name layout.
+func findMirror(rules []MirrorRule, repo string) (string, string, bool) {
+	repo = getRealRepoPath(repo)
+
+	for _, rule := range rules {
+		if strings.HasSuffix(rule.Pattern, "*") {
+			prefix := strings.TrimSuffix(rule.Pattern, "*")
+			root := guessRepoRoot(repo)
+
+			if strings.HasPrefix(root, prefix) && len(root) > len(prefix) {
+				return root, strings.Replace(rule.URL, "*", strings.TrimPrefix(root, prefix), 1), true
+			}
+		} else if repo == rule.Pattern || strings.HasPrefix(repo, rule.Pattern+"/") {
+			return rule.Pattern, rule.URL, true
+		}
+	}
+
+	return "", "", false
+}
+
+func (e *engine) mirrorRefreshCommand(packageDir string, url string) ([]string, error) {
+	if exists, _ := pathExists(path.Join(packageDir, ".git")); exists {
+		output, err := e.commandOutput(e.command(packageDir, "git", "remote"))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		remote := "origin"
+		if remotes := strings.Fields(string(output)); len(remotes) > 0 && !stringInSlice("origin", remotes) {
+			remote = remotes[0]
+		}
+
+		// fetch into the usual tracking refs so branch and upstream lookups keep working,
+		// without rewriting the remote's configured url
+		return []string{"git", "fetch", "--tags", url, fmt.Sprintf("+refs/heads/*:refs/remotes/%s/*", remote)}, nil
+	} else if exists, _ := pathExists(path.Join(packageDir, ".hg")); exists {
+		return []string{"hg", "pull", url}, nil
+	} else if exists, _ := pathExists(path.Join(packageDir, ".bzr")); exists {
+		return []string{"bzr", "pull", url}, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,42 @@
+package bunch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMirrorRules(t *testing.T) {
+	rules, err := parseMirrorRules("github.com/* -> https://git.internal/mirror/github.com/*, example.com/tools/lib -> ssh://git.internal/lib.git")
+	assert.Nil(t, err, "valid rules should parse")
+	assert.Equal(t, []MirrorRule{
+		{Pattern: "github.com/*", URL: "https://git.internal/mirror/github.com/*"},
+		{Pattern: "example.com/tools/lib", URL: "ssh://git.internal/lib.git"},
+	}, rules, "rules should be parsed in order")
+
+	_, err = parseMirrorRules("github.com/* https://git.internal/mirror")
+	assert.NotNil(t, err, "rules without an arrow should be rejected")
+
+	_, err = parseMirrorRules("github.com/*/lib -> https://git.internal/lib")
+	assert.NotNil(t, err, "a * in the middle of a pattern should be rejected")
+
+	_, err = parseMirrorRules("example.com/lib -> https://git.internal/*")
+	assert.NotNil(t, err, "a * in the url needs one in the pattern")
+}
+
+func TestFindMirror(t *testing.T) {
+	rules, _ := parseMirrorRules("example.com/tools/lib -> ssh://git.internal/lib.git, github.com/* -> https://git.internal/mirror/github.com/*")
+
+	root, url, ok := findMirror(rules, "github.com/juju/errors/...")
+	assert.True(t, ok, "wildcard rule should match")
+	assert.Equal(t, "github.com/juju/errors", root, "root should be the repository, not the subpackage")
+	assert.Equal(t, "https://git.internal/mirror/github.com/juju/errors", url, "wildcard should be substituted")
+
+	root, url, ok = findMirror(rules, "example.com/tools/lib/sub")
+	assert.True(t, ok, "exact rule should match subpackages")
+	assert.Equal(t, "example.com/tools/lib", root, "exact rule should name the root")
+	assert.Equal(t, "ssh://git.internal/lib.git", url, "exact rule url should be used as is")
+
+	_, _, ok = findMirror(rules, "golang.org/x/net")
+	assert.False(t, ok, "unmatched packages should not be rewritten")
+}
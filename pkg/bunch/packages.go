@@ -0,0 +1,1546 @@
+package bunch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+func pathExists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, errors.Trace(err)
+}
+
+func stringInSlice(needle string, haystack []string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *engine) getPackageRootDir(repo string) (string, error) { // move backwards through the package name, looking for a .git/.hg dir to find the package "root"
+	gopath := e.gopath
+	resultPath := path.Join(gopath, "src", repo)
+
+	parts := strings.Split(repo, "/")
+	for i := len(parts) - 1; i >= 0; i-- {
+		repoPortion := path.Join(parts[:i]...)
+		candidatePath := path.Join(gopath, "src", repoPortion)
+
+		gitDir := path.Join(candidatePath, ".git")
+		hgDir := path.Join(candidatePath, ".hg")
+		bzrDir := path.Join(candidatePath, ".bzr")
+
+		if exists, _ := pathExists(gitDir); exists {
+			resultPath = candidatePath
+			break
+		}
+
+		if exists, _ := pathExists(hgDir); exists {
+			resultPath = candidatePath
+			break
+		}
+
+		if exists, _ := pathExists(bzrDir); exists {
+			resultPath = candidatePath
+			break
+		}
+	}
+
+	return resultPath, nil
+}
+
+func (e *engine) fetchPackage(repo string) error { // safe to call concurrently for different repos, it doesn't change directory
+	gopath := e.gopath
+	packageDir := path.Join(gopath, "src", getRealRepoPath(repo))
+
+	if _, err := os.Stat(packageDir); err != nil {
+		if os.IsNotExist(err) {
+			repoRoot, err := e.resolveRepoRoot(repo)
+			if err != nil {
+				return newError(KindFetch, "failed finding repository for package %s: %s", repo, err)
+			}
+
+			// another package from the same repository may have cloned it already
+			if exists, _ := pathExists(path.Join(gopath, "src", repoRoot.Root)); !exists {
+				step := Step{Action: "cloning", Subject: repo, Verbose: true}
+				e.reporter.Begin(step)
+
+				err := e.cloneRepo(repoRoot)
+
+				e.reportStep(step, err)
+
+				if err != nil {
+					return newError(KindFetch, "failed cloning repo for package %s: %s", repo, err)
+				}
+
+				return nil
+			}
+		}
+	}
+
+	packageDir, err := e.getPackageRootDir(getRealRepoPath(repo))
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	step := Step{Action: "refreshing", Subject: repo, Verbose: true}
+	e.reporter.Begin(step)
+
+	var refreshCommand []string
+
+	if _, mirrorURL, ok := findMirror(e.mirrorRules, repo); ok {
+		refreshCommand, err = e.mirrorRefreshCommand(packageDir, mirrorURL)
+		if err != nil {
+			e.reporter.End(step, StepFailed, "")
+			return errors.Trace(err)
+		}
+	} else if exists, _ := pathExists(path.Join(packageDir, ".git")); exists {
+		refreshCommand = []string{"git", "fetch", "--all"}
+	} else if exists, _ := pathExists(path.Join(packageDir, ".hg")); exists {
+		refreshCommand = []string{"hg", "pull"}
+	} else if exists, _ := pathExists(path.Join(packageDir, ".bzr")); exists {
+		refreshCommand = []string{"bzr", "pull"}
+	} else if exists, _ := pathExists(path.Join(packageDir, ".svn")); exists {
+		refreshCommand = []string{"svn", "up"}
+	}
+
+	if len(refreshCommand) > 0 {
+		_, err := e.runNetworkCommand(func() *exec.Cmd {
+			return e.command(packageDir, refreshCommand[0], refreshCommand[1:]...)
+		})
+
+		e.reportStep(step, err)
+
+		if err != nil {
+			return newError(KindFetch, "failed updating repo for package %s: %s", repo, err)
+		}
+	} else {
+		e.reporter.End(step, StepSkipped, "")
+	}
+
+	return nil
+}
+
+func (e *engine) fetchGroupKey(repo string) string { // repos sharing a key are fetched one after another
+	if packageDir, err := e.getPackageRootDir(getRealRepoPath(repo)); err == nil {
+		if exists, _ := pathExists(packageDir); exists {
+			return packageDir
+		}
+	}
+
+	parts := strings.Split(getRealRepoPath(repo), "/")
+	if len(parts) > 3 {
+		parts = parts[:3]
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// fetchPackagesInParallel fetches repos with up to workers at once; once a fetch fails, the
+// groups that haven't started yet are skipped and the first failure is returned
+func (e *engine) fetchPackagesInParallel(repos []string, workers int) error {
+	groups := make(map[string][]string)
+	groupKeys := []string{}
+
+	for _, repo := range repos {
+		key := e.fetchGroupKey(repo)
+		if _, seen := groups[key]; !seen {
+			groupKeys = append(groupKeys, key)
+		}
+		groups[key] = append(groups[key], repo)
+	}
+
+	fetchCtx, cancel := context.WithCancel(e.ctx)
+	defer cancel()
+
+	jobs := make(chan []string)
+	errs := make(chan error, len(groupKeys))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for group := range jobs {
+				for _, repo := range group {
+					if fetchCtx.Err() != nil {
+						break
+					}
+
+					step := Step{Action: "fetching", Subject: repo}
+					e.reporter.Begin(step)
+
+					err := e.fetchPackage(repo)
+					e.reportStep(step, err)
+
+					if err != nil {
+						errs <- err
+						cancel()
+						break
+					}
+				}
+			}
+		}()
+	}
+
+	for _, key := range groupKeys {
+		jobs <- groups[key]
+	}
+
+	close(jobs)
+	wg.Wait()
+	close(errs)
+
+	if err, failed := <-errs; failed {
+		return errors.Trace(err)
+	}
+
+	return errors.Trace(e.ctx.Err())
+}
+
+func targetDetail(target Target) string {
+	if target == (Target{}) {
+		return ""
+	}
+
+	return "for " + target.String()
+}
+
+func (e *engine) goCommandFor(dir string, target Target, settings BuildSettings, verb string, repo string) *exec.Cmd { // a go command building repo for target with settings
+	cmd := e.command(dir, "go", append(append([]string{verb}, settings.args()...), repo)...)
+	cmd.Env = append(cmd.Env, append(append([]string{}, settings.Env...), target.env()...)...)
+
+	return cmd
+}
+
+func (e *engine) buildPackage(repo string, target Target, settings BuildSettings) error {
+	packageDir := path.Join(e.gopath, "src", getRealRepoPath(repo))
+
+	step := Step{Action: "building package", Subject: repo, Detail: targetDetail(target), Verbose: true}
+	e.reporter.Begin(step)
+
+	_, err := e.runCommand(e.goCommandFor(packageDir, target, settings, "build", repo))
+
+	e.reportStep(step, err)
+
+	if err != nil {
+		return newError(KindBuild, "failed building package %s: %s", strings.TrimSpace(repo+" "+targetDetail(target)), err)
+	}
+
+	return nil
+}
+
+func (e *engine) installPackage(repo string, target Target, settings BuildSettings) error {
+	packageDir := path.Join(e.gopath, "src", getRealRepoPath(repo))
+
+	step := Step{Action: "installing package", Subject: repo, Detail: targetDetail(target), Verbose: true}
+	e.reporter.Begin(step)
+
+	_, err := e.runCommand(e.goCommandFor(packageDir, target, settings, "install", repo))
+
+	e.reportStep(step, err)
+
+	if err != nil {
+		return newError(KindBuild, "failed installing package %s: %s", strings.TrimSpace(repo+" "+targetDetail(target)), err)
+	}
+
+	return nil
+}
+
+func (e *engine) setPackageVersion(repo string, version string, humanVersion string) error {
+	if version == "" {
+		return nil
+	}
+
+	packageDir, err := e.getPackageRootDir(getRealRepoPath(repo))
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	step := Step{Action: "setting version of", Subject: repo, Detail: fmt.Sprintf("to %s (resolved as %s)", humanVersion, version), Verbose: true}
+
+	var checkoutCommand []string
+	var vcsDir string
+	if exists, _ := pathExists(path.Join(packageDir, ".git")); exists {
+		checkoutCommand, vcsDir = []string{"git", "checkout", version}, ".git"
+	} else if exists, _ := pathExists(path.Join(packageDir, ".hg")); exists {
+		checkoutCommand, vcsDir = []string{"hg", "update", "-c", version}, ".hg"
+	} else if exists, _ := pathExists(path.Join(packageDir, ".bzr")); exists {
+		checkoutCommand, vcsDir = []string{"bzr", "update", "-r", version}, ".bzr"
+	} else {
+		e.reporter.End(step, StepSkipped, "skipped, unknown repo type")
+		return nil
+	}
+
+	err = e.checkCleanCheckout(repo, packageDir, vcsDir, version)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	e.reporter.Begin(step)
+
+	_, err = e.runCommand(e.command(packageDir, checkoutCommand[0], checkoutCommand[1:]...))
+
+	e.reportStep(step, err)
+
+	if err != nil {
+		return errors.Annotatef(err, "failed setting version of package %s", repo)
+	}
+
+	return nil
+}
+
+// localChangesCommands show local changes to tracked files in a checkout, by VCS directory
+var localChangesCommands = map[string][]string{
+	".git": {"git", "status", "--porcelain", "--untracked-files=no"},
+	".hg":  {"hg", "status", "--modified", "--added", "--removed", "--deleted"},
+	".bzr": {"bzr", "status", "--short", "--versioned"},
+}
+
+func (e *engine) checkoutRevisionAt(repoDir string, vcsDir string, rev string) string { // "" rev is the checkout itself; "" if rev is unknown
+	var output string
+	var err error
+
+	switch vcsDir {
+	case ".git":
+		output, err = e.outputAt(repoDir, "git", "rev-parse", "-q", "--verify", orDefault(rev, "HEAD")+"^{commit}")
+	case ".hg":
+		output, err = e.outputAt(repoDir, "hg", "log", "-r", orDefault(rev, "."), "--template", "{node}")
+	case ".bzr":
+		if rev == "" {
+			output, err = e.outputAt(repoDir, "bzr", "revision-info", "--tree")
+		} else {
+			output, err = e.outputAt(repoDir, "bzr", "revision-info", "-r", rev)
+		}
+	}
+
+	if err != nil {
+		return ""
+	}
+
+	return output
+}
+
+func (e *engine) checkCleanCheckout(repo string, repoDir string, vcsDir string, version string) error { // refuses to move a repository with local changes
+	command := localChangesCommands[vcsDir]
+
+	changes, err := e.outputAt(repoDir, command[0], command[1:]...)
+	if err != nil {
+		return errors.Annotatef(err, "failed checking %s for local changes", repoDir)
+	}
+
+	if changes == "" {
+		return nil
+	}
+
+	if current := e.checkoutRevisionAt(repoDir, vcsDir, ""); current != "" && current == e.checkoutRevisionAt(repoDir, vcsDir, version) {
+		return nil
+	}
+
+	return newError(KindDirtyVendor, "package %s has local changes in %s, commit or discard them first:\n%s", repo, repoDir, changes)
+}
+
+func countNonEmptyStrings(ar []string) int {
+	counter := 0
+
+	for _, el := range ar {
+		if el != "" {
+			counter += 1
+		}
+	}
+
+	return counter
+}
+
+func getRealRepoPath(pack string) string {
+	if strings.HasSuffix(pack, "...") {
+		return strings.Replace(pack, "/...", "", -1)
+	} else {
+		return pack
+	}
+}
+
+type PackageRecencyInfo struct {
+	LatestCommit         string `json:"latest_commit"`
+	LatestUpstreamCommit string `json:"latest_upstream_commit"`
+	InstalledCommit      string `json:"installed_commit"`
+	UpstreamDiffCount    int    `json:"upstream_diff_count"`
+	InstalledDiffCount   int    `json:"installed_diff_count"`
+
+	PackageTagInfo
+}
+
+func (e *engine) checkPackageRecency(pack Package) (bool, PackageRecencyInfo, error) { // bool = needsUpdate
+	NilInfo := PackageRecencyInfo{}
+
+	repo := getRealRepoPath(pack.Repo)
+	version, err := e.getLatestVersionMatchingPattern(pack.Repo, pack.Version, pack.Branch)
+
+	if err != nil {
+		return false, NilInfo, errors.Trace(err)
+	}
+
+	packageDir := path.Join(e.gopath, "src", repo)
+
+	if exists, _ := pathExists(packageDir); !exists {
+		return true, NilInfo, nil
+	}
+
+	packageDir, err = e.getPackageRootDir(repo)
+	if err != nil {
+		return false, NilInfo, errors.Trace(err)
+	}
+
+	var repoType string
+
+	if exists, _ := pathExists(path.Join(packageDir, ".git")); exists {
+		repoType = "git"
+	} else if exists, _ := pathExists(path.Join(packageDir, ".hg")); exists {
+		repoType = "hg"
+	} else {
+		return true, NilInfo, nil // force an update
+	}
+
+	var getVersionCommand, getHEADCommand, getUpstreamVersionCommand, getUpstreamDiffCommand, getInstalledDiffCommand []string
+
+	if repoType == "git" {
+		upstreamRef, err := e.getGitUpstreamRef(packageDir, pack.Branch)
+		if err != nil {
+			return false, NilInfo, errors.Trace(err)
+		}
+
+		getVersionCommand = []string{"git", "rev-parse", "-q", "--verify", version}
+		getHEADCommand = []string{"git", "rev-parse", "-q", "--verify", "HEAD"}
+		getUpstreamVersionCommand = []string{"git", "rev-parse", "-q", "--verify", upstreamRef}
+		getUpstreamDiffCommand = []string{"git", "log", fmt.Sprintf("HEAD..%s", upstreamRef), "--pretty=oneline"}
+		getInstalledDiffCommand = []string{"git", "log", fmt.Sprintf("HEAD..%s", version), "--pretty=oneline"}
+	} else if repoType == "hg" {
+		getVersionCommand = []string{"hg", "identify", "-ir", version}
+		getHEADCommand = []string{"hg", "identify", "-i"}
+		upstreamRef := "tip" // imperfect, but there's no git equivalent to this
+		if pack.Branch != "" {
+			upstreamRef = pack.Branch
+		}
+
+		getUpstreamVersionCommand = []string{"hg", "identify", "-ir", upstreamRef}
+		getUpstreamDiffCommand = []string{"echo"}
+		getInstalledDiffCommand = []string{"echo"} // can't really even approximate this
+	}
+
+	getVersionOutput, err := e.commandOutput(e.command(packageDir, getVersionCommand[0], getVersionCommand[1:]...))
+	if err != nil {
+		return false, NilInfo, errors.Trace(err)
+	}
+
+	getUpstreamVersionOutput, err := e.commandOutput(e.command(packageDir, getUpstreamVersionCommand[0], getUpstreamVersionCommand[1:]...))
+	if err != nil {
+		return false, NilInfo, errors.Trace(err)
+	}
+
+	getHEADOutput, err := e.commandOutput(e.command(packageDir, getHEADCommand[0], getHEADCommand[1:]...))
+	if err != nil {
+		return false, NilInfo, errors.Trace(err)
+	}
+
+	upstreamDiffCount := 0
+	getUpstreamDiffOutput, err := e.runCommand(e.command(packageDir, getUpstreamDiffCommand[0], getUpstreamDiffCommand[1:]...))
+	if err == nil {
+		upstreamDiffCount = countNonEmptyStrings(strings.Split(strings.TrimSpace(string(getUpstreamDiffOutput)), "\n"))
+	}
+
+	installedDiffCount := 0
+	getInstalledDiffOutput, err := e.runCommand(e.command(packageDir, getInstalledDiffCommand[0], getInstalledDiffCommand[1:]...))
+	if err == nil {
+		installedDiffCount = countNonEmptyStrings(strings.Split(strings.TrimSpace(string(getInstalledDiffOutput)), "\n"))
+	}
+
+	versionString := strings.TrimSpace(string(getVersionOutput))
+	HEADString := strings.TrimSpace(string(getHEADOutput))
+	upstreamVersionString := strings.TrimSpace(string(getUpstreamVersionOutput))
+
+	recencyInfo := PackageRecencyInfo{
+		LatestCommit:         versionString,
+		LatestUpstreamCommit: upstreamVersionString,
+		InstalledCommit:      HEADString,
+		UpstreamDiffCount:    upstreamDiffCount,
+		InstalledDiffCount:   installedDiffCount,
+	}
+
+	if repoType == "git" {
+		recencyInfo.PackageTagInfo, err = e.getPackageTagInfo(packageDir, pack.Version)
+		if err != nil {
+			return false, NilInfo, errors.Trace(err)
+		}
+	}
+
+	installedRevision, err := e.getInstalledRevision(repo)
+	if err != nil {
+		return false, NilInfo, errors.Trace(err)
+	}
+
+	for _, target := range append([]Target{{}}, e.buildTargets...) {
+		built, err := e.builtFromCurrent(repo, installedRevision, target, pack.Build)
+		if err != nil {
+			return false, NilInfo, errors.Trace(err)
+		}
+
+		if !built {
+			return true, recencyInfo, nil
+		}
+	}
+
+	if versionString != HEADString {
+		if pack.LockedVersion != HEADString {
+			if version == "" {
+				return false, recencyInfo, nil
+			} else {
+				return true, recencyInfo, nil
+			}
+		} else {
+			return false, recencyInfo, nil
+		}
+	} else {
+		if pack.LockedVersion != "" && pack.LockedVersion != HEADString {
+			return true, recencyInfo, nil
+		} else {
+			return false, recencyInfo, nil
+		}
+	}
+
+	return false, NilInfo, nil
+}
+
+func parsePackage(packString string, defaultHost string) Package {
+	parts := strings.Split(packString, "@")
+	pack := Package{}
+
+	if len(parts) == 2 {
+		pack.Repo = parts[0]
+		pack.Version = parts[1]
+	} else {
+		pack.Repo = parts[0]
+	}
+
+	repoParts := strings.Split(pack.Repo, "/")
+	if len(repoParts) == 2 {
+		if !strings.Contains(repoParts[0], ".") {
+			// github shorthand (or whichever host default_host names)
+			pack.Repo = fmt.Sprintf("%s/%s", orDefault(defaultHost, "github.com"), pack.Repo)
+		}
+	}
+
+	return pack
+}
+
+func (e *engine) installPackagesFromBunchfile(b *BunchFile, forceUpdate bool, checkUpstream bool, respectLocked bool) (InstallPlan, *InstallReport, error) {
+	return e.installPackages(b.withBuildDefaults(), false, forceUpdate, checkUpstream, respectLocked)
+}
+
+// installPackagesFromRepoStrings installs the given packages, built with the settings b has for
+// them if b isn't nil
+func (e *engine) installPackagesFromRepoStrings(b *BunchFile, packageStrings []string, installGlobally bool, forceUpdate bool, checkUpstream bool, respectLocked bool) (InstallPlan, *InstallReport, error) {
+	packages := make([]Package, len(packageStrings))
+	for i, packString := range packageStrings {
+		packages[i] = parsePackage(packString, e.defaultHost)
+
+		if b != nil {
+			packages[i].Build = b.Defaults
+			if index, present := b.PackageIndex(packages[i].Repo); present {
+				packages[i].Build = b.Defaults.Merge(b.Packages[index].Build)
+			}
+		}
+	}
+
+	return e.installPackages(packages, installGlobally, forceUpdate, checkUpstream, respectLocked)
+}
+
+func (e *engine) resolvePackageTarget(pack Package, respectLocked bool) (string, error) {
+	version := pack.Version
+
+	if !pack.IsLink {
+		var err error
+		version, err = e.getLatestVersionMatchingPattern(pack.Repo, pack.Version, pack.Branch)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+	}
+
+	if pack.LockedVersion != "" && respectLocked {
+		err := e.verifyLockedRevision(pack)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+
+		version = pack.LockedVersion
+	}
+
+	return version, nil
+}
+
+func (e *engine) verifyLockedRevision(pack Package) error { // a locked revision missing from its repository means Bunchfile.lock is stale
+	repoDir, err := e.getPackageRootDir(getRealRepoPath(pack.Repo))
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	if exists, _ := pathExists(path.Join(repoDir, ".git")); !exists {
+		return nil
+	}
+
+	if _, err := e.gitOutputAt(repoDir, "rev-parse", "-q", "--verify", pack.LockedVersion+"^{commit}"); err == nil {
+		return nil
+	}
+
+	// the commit may just be newer than the last fetch
+	err = e.fetchPackage(pack.Repo)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	if _, err := e.gitOutputAt(repoDir, "rev-parse", "-q", "--verify", pack.LockedVersion+"^{commit}"); err != nil {
+		return newError(KindLockMismatch, "Bunchfile.lock pins %s to %s, which its repository doesn't have even after fetching (make sure the commit is pushed to %s, then run 'bunch install' again)", pack.Repo, pack.LockedVersion, pack.Repo)
+	}
+
+	return nil
+}
+
+type InstallStep struct {
+	Package          Package `json:"-"`
+	Repo             string  `json:"repo"`
+	Link             bool    `json:"link,omitempty"`
+	Fetch            bool    `json:"fetch,omitempty"`
+	Install          bool    `json:"install,omitempty"`
+	Build            bool    `json:"build,omitempty"`
+	NeedsUpdate      bool    `json:"needs_update"`
+	PreviousRevision string  `json:"previous_revision,omitempty"`
+	Target           string  `json:"target,omitempty"` // only resolved up front for dry runs; installs resolve it after fetching
+}
+
+type InstallPlan struct {
+	Steps           []InstallStep `json:"steps"`
+	ForceUpdate     bool          `json:"force_update"`
+	RespectLocked   bool          `json:"respect_locked"`
+	AnyNeededUpdate bool          `json:"any_needed_update"`
+	Global          bool          `json:"global"`
+	Targets         []Target      `json:"targets,omitempty"` // built for besides the host
+	Pins            []Package     `json:"-"`                 // versions transitive dependencies are checked out at
+}
+
+func (e *engine) installPackages(packages []Package, installGlobally bool, forceUpdate bool, checkUpstream bool, respectLocked bool) (InstallPlan, *InstallReport, error) { // the report is nil for dry runs
+	if !installGlobally {
+		err := e.useVendor()
+		if err != nil {
+			return InstallPlan{}, nil, errors.Trace(err)
+		}
+	}
+
+	plan, err := e.planInstall(packages, forceUpdate, checkUpstream, respectLocked)
+	if err != nil {
+		return plan, nil, errors.Trace(err)
+	}
+
+	plan.Global = installGlobally
+	plan.Targets = e.buildTargets
+	plan.Pins = e.dependencyPins(packages, installGlobally)
+
+	if e.dryRun {
+		return plan, nil, e.reportInstallPlan(plan)
+	}
+
+	report, err := e.executeInstallPlan(plan)
+
+	return plan, report, err
+}
+
+func (e *engine) planInstall(packages []Package, forceUpdate bool, checkUpstream bool, respectLocked bool) (InstallPlan, error) {
+	gopath := e.gopath
+
+	plan := InstallPlan{
+		Steps:         []InstallStep{},
+		ForceUpdate:   forceUpdate,
+		RespectLocked: respectLocked,
+	}
+
+	for _, pack := range packages {
+		step := InstallStep{Package: pack, Repo: pack.Repo}
+
+		if pack.IsLink {
+			if exists, _ := pathExists(path.Join(gopath, "src", pack.Repo)); !exists {
+				step.Link = true
+			}
+
+			step.Install = forceUpdate
+			step.Build = forceUpdate && !pack.IsSelf
+
+			plan.Steps = append(plan.Steps, step)
+			continue
+		}
+
+		previousRevision, err := e.getInstalledRevision(pack.Repo)
+		if err != nil {
+			return plan, errors.Trace(err)
+		}
+
+		needsUpdate, _, err := e.checkPackageRecency(pack)
+		if err != nil {
+			return plan, errors.Trace(err)
+		}
+
+		if needsUpdate {
+			plan.AnyNeededUpdate = true
+		}
+
+		step.PreviousRevision = previousRevision
+		step.NeedsUpdate = needsUpdate
+		step.Fetch = (needsUpdate || forceUpdate) && checkUpstream
+		step.Install = needsUpdate || forceUpdate
+		step.Build = step.Install && !pack.IsSelf
+
+		if e.dryRun && step.Install && previousRevision != "" {
+			if step.Fetch {
+				// refreshing only moves remote-tracking refs, the checkout is left alone
+				err = e.fetchPackage(pack.Repo)
+				if err != nil {
+					return plan, errors.Trace(err)
+				}
+			}
+
+			step.Target, err = e.resolvePackageTarget(pack, respectLocked)
+			if err != nil {
+				return plan, errors.Trace(err)
+			}
+		}
+
+		plan.Steps = append(plan.Steps, step)
+	}
+
+	return plan, nil
+}
+
+func (e *engine) reportInstallPlan(plan InstallPlan) error { // describes what a dry run would do
+	anyChanges := false
+
+	for _, step := range plan.Steps {
+		pack := step.Package
+
+		if step.Link {
+			e.reporter.Message(MessageWarning, fmt.Sprintf("package %s ... would link to %s", pack.Repo, pack.LinkTarget))
+			anyChanges = true
+		}
+
+		if !step.Install {
+			if e.verbose && !step.Link {
+				e.reporter.Message(MessageSuccess, fmt.Sprintf("package %s ... up to date", pack.Repo))
+			}
+			continue
+		}
+
+		anyChanges = true
+
+		if !pack.IsLink {
+			if step.PreviousRevision == "" {
+				e.reporter.Message(MessageWarning, fmt.Sprintf("package %s ... would be fetched and checked out at %s", pack.Repo, orDefault(pack.Version, "the default branch")))
+			} else if step.Target != "" {
+				changed, err := e.previewPackageUpdate(pack, step.Target)
+				if err != nil {
+					return errors.Trace(err)
+				}
+
+				if !changed {
+					e.reporter.Message(MessageWarning, fmt.Sprintf("package %s ... would stay at %s", pack.Repo, ShortCommit(step.PreviousRevision)))
+				}
+			}
+		}
+
+		if step.Build && len(plan.Targets) > 0 {
+			e.reporter.Message(MessageInfo, fmt.Sprintf("  - would build and install %s (also for %s)", pack.Repo, joinTargets(plan.Targets)))
+		} else if step.Build {
+			e.reporter.Message(MessageInfo, fmt.Sprintf("  - would build and install %s", pack.Repo))
+		}
+	}
+
+	if !anyChanges {
+		e.reporter.Message(MessageSuccess, "up to date, nothing would change")
+	}
+
+	return nil
+}
+
+func (e *engine) executeInstallPlan(plan InstallPlan) (*InstallReport, error) {
+	var snapshotRepos []string // nil snapshots the whole vendor tree
+
+	if plan.Global {
+		snapshotRepos = []string{}
+		for _, step := range plan.Steps {
+			if !step.Package.IsLink {
+				snapshotRepos = append(snapshotRepos, step.Package.Repo)
+			}
+		}
+	}
+
+	snapshot, err := e.snapshotVendor(snapshotRepos)
+	if err != nil {
+		return nil, errors.Annotate(err, "failed recording revisions before install")
+	}
+
+	e.activeSnapshot = snapshot
+	defer func() {
+		e.activeSnapshot = nil
+	}()
+
+	report, err := e.executeInstallSteps(plan, snapshot)
+	if err != nil {
+		e.reporter.Message(MessageWarning, "install failed, rolling back")
+
+		rollbackErr := snapshot.Restore()
+		if rollbackErr != nil {
+			return nil, errors.Annotatef(err, "rollback failed (%s), vendor tree may be inconsistent", rollbackErr)
+		}
+
+		return nil, errors.Annotate(err, "vendor tree restored to its previous state")
+	}
+
+	err = snapshot.Discard()
+	if err != nil {
+		return nil, errors.Annotate(err, "failed removing artifacts saved for rollback")
+	}
+
+	return report, nil
+}
+
+func (e *engine) executeInstallSteps(plan InstallPlan, snapshot *VendorSnapshot) (*InstallReport, error) {
+	gopath := e.gopath
+
+	prefetched := false
+
+	if e.parallelism > 1 {
+		fetchRepos := []string{}
+		for _, step := range plan.Steps {
+			if step.Fetch {
+				fetchRepos = append(fetchRepos, step.Package.Repo)
+			}
+		}
+
+		err := e.fetchPackagesInParallel(fetchRepos, e.parallelism)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		prefetched = true
+	}
+
+	report := InstallReport{Packages: []InstalledPackage{}}
+	fetcher := e.newDependencyFetcher(plan.Pins, plan.RespectLocked)
+
+	for _, step := range plan.Steps {
+		pack := step.Package
+
+		if err := e.ctx.Err(); err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		if step.Link {
+			err := os.MkdirAll(filepath.Dir(path.Join(gopath, "src", pack.Repo)), 0755)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+
+			step := Step{Action: "setting up local package", Subject: pack.Repo}
+			if pack.IsSelf {
+				step = Step{Action: "setting up link for", Subject: pack.Repo}
+			}
+
+			e.reporter.Begin(step)
+
+			err = os.Symlink(pack.LinkTarget, path.Join(gopath, "src", pack.Repo))
+			e.reportStep(step, err)
+
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+
+			snapshot.RecordCreated(path.Join(gopath, "src", pack.Repo))
+
+			report.Packages = append(report.Packages, InstalledPackage{Repo: pack.Repo, Version: pack.Version, Action: "linked"})
+		}
+
+		if step.Fetch {
+			if !prefetched {
+				fetchStep := Step{Action: "fetching", Subject: pack.Repo}
+				e.reporter.Begin(fetchStep)
+
+				err := e.fetchPackage(pack.Repo)
+				e.reportStep(fetchStep, err)
+
+				if err != nil {
+					return nil, errors.Trace(err)
+				}
+			}
+
+			err := e.fetchPackageDependencies(pack.Repo, fetcher)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+	}
+
+	for _, step := range plan.Steps {
+		pack := step.Package
+
+		if err := e.ctx.Err(); err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		if step.Install {
+			if step.Build {
+				err := snapshot.SaveArtifacts(pack.Repo)
+				if err != nil {
+					return nil, errors.Trace(err)
+				}
+			}
+
+			installStep := Step{Action: "installing", Subject: pack.Repo}
+			e.reporter.Begin(installStep)
+
+			err := e.installStepPackage(fetcher, step)
+			e.reportStep(installStep, err)
+
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+
+			if !pack.IsLink {
+				installedRevision, err := e.getInstalledRevision(pack.Repo)
+				if err != nil {
+					return nil, errors.Trace(err)
+				}
+
+				action := "updated"
+				if step.PreviousRevision == "" {
+					action = "installed"
+				} else if step.PreviousRevision == installedRevision {
+					action = "rebuilt"
+				}
+
+				report.Packages = append(report.Packages, InstalledPackage{
+					Repo:            pack.Repo,
+					Version:         pack.Version,
+					Action:          action,
+					PreviousCommit:  step.PreviousRevision,
+					InstalledCommit: installedRevision,
+				})
+			}
+		} else {
+			if !pack.IsLink {
+				report.Packages = append(report.Packages, InstalledPackage{
+					Repo:            pack.Repo,
+					Version:         pack.Version,
+					Action:          "skipped",
+					PreviousCommit:  step.PreviousRevision,
+					InstalledCommit: step.PreviousRevision,
+				})
+			}
+
+			e.reporter.End(Step{Action: "installing", Subject: pack.Repo, Verbose: true}, StepSkipped, "skipped, up to date")
+		}
+	}
+
+	if !plan.AnyNeededUpdate && !e.verbose && !plan.ForceUpdate {
+		e.reporter.Message(MessageSuccess, "up to date (use 'bunch update' to force update)")
+	}
+
+	return &report, nil
+}
+
+func (e *engine) installStepPackage(fetcher *dependencyFetcher, step InstallStep) error { // checks out, builds and installs one package
+	pack := step.Package
+
+	version, err := fetcher.packageTarget(pack)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	if !pack.IsLink {
+		err := e.setPackageVersion(pack.Repo, version, pack.Version)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	if !step.Build {
+		return nil
+	}
+
+	targets, err := e.crossTargets()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	for _, target := range append([]Target{{}}, targets...) {
+		err := e.buildPackage(pack.Repo, target, pack.Build)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		err = e.installPackage(pack.Repo, target, pack.Build)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		if !pack.IsLink {
+			err = e.recordBuild(pack.Repo, target, pack.Build)
+			if err != nil {
+				return errors.Annotatef(err, "failed recording build of %s", pack.Repo)
+			}
+		}
+	}
+
+	return nil
+}
+
+type GoList struct {
+	Name        string
+	Doc         string
+	ImportPath  string
+	Imports     []string
+	TestImports []string
+	Deps        []string
+}
+
+func isEmptyDir(name string) (bool, error) {
+	entries, err := ioutil.ReadDir(name)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	return len(entries) == 0, nil
+}
+
+func cleanEmpties(emptyPath string) error {
+	higherPath := filepath.Dir(emptyPath)
+	if empty, _ := isEmptyDir(higherPath); empty {
+		err := os.Remove(higherPath)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		highestPath := filepath.Dir(higherPath)
+		if empty, _ := isEmptyDir(highestPath); empty {
+			err := os.Remove(highestPath)
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+type RemovalStep struct {
+	Repo     string
+	SrcPath  string
+	PkgPaths []string // archives of the package and its subpackages, for every target built
+	BinPaths []string // binaries, including cross-compiled ones in bin/<goos>_<goarch>
+	Paths    []string // the subset of the above that currently exist
+}
+
+func globDirs(pattern string) []string { // the directories matching pattern, skipping files
+	dirs := []string{}
+
+	matches, _ := filepath.Glob(pattern)
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && info.IsDir() {
+			dirs = append(dirs, match)
+		}
+	}
+
+	return dirs
+}
+
+// packageArtifacts lists where builds of pack put its archive and binary, for every target built
+// so far: pkg/<goos>_<goarch>/<pack>.a and bin/[<goos>_<goarch>/]<name>[.exe]
+func (e *engine) packageArtifacts(pack string) ([]string, []string) {
+	gopath := e.gopath
+
+	archives := []string{}
+	binaries := []string{}
+
+	for _, pkgDir := range globDirs(path.Join(gopath, "pkg", "*_*")) { // e.g. pkg/linux_arm64, but not pkg/mod
+		archives = append(archives, fmt.Sprintf("%s.a", path.Join(pkgDir, pack)))
+	}
+
+	_, binFile := path.Split(pack)
+
+	if binFile != "" {
+		binaries = append(binaries, path.Join(gopath, "bin", binFile), path.Join(gopath, "bin", binFile+".exe"))
+
+		for _, binDir := range globDirs(path.Join(gopath, "bin", "*_*")) { // cross-compiled, e.g. bin/linux_arm64
+			binaries = append(binaries, path.Join(binDir, binFile), path.Join(binDir, binFile+".exe"))
+		}
+	}
+
+	return archives, binaries
+}
+
+func (e *engine) planPackageRemoval(pack string) RemovalStep {
+	gopath := e.gopath
+
+	step := RemovalStep{
+		Repo:    pack,
+		SrcPath: path.Join(gopath, "src", pack),
+	}
+
+	archives, binaries := e.packageArtifacts(pack)
+	for _, archive := range archives {
+		step.PkgPaths = append(step.PkgPaths, archive, strings.TrimSuffix(archive, ".a"))
+	}
+	step.BinPaths = binaries
+
+	for _, candidatePath := range append(append([]string{step.SrcPath}, step.PkgPaths...), step.BinPaths...) {
+		if exists, _ := pathExists(candidatePath); exists {
+			step.Paths = append(step.Paths, candidatePath)
+		}
+	}
+
+	return step
+}
+
+func (e *engine) removePackage(pack string) error {
+	step := e.planPackageRemoval(pack)
+
+	for _, removePath := range step.Paths {
+		err := os.RemoveAll(removePath)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	err := e.forgetBuild(pack)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = cleanEmpties(step.SrcPath)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	for _, pkgPath := range step.PkgPaths {
+		err = cleanEmpties(pkgPath)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	return nil
+}
+
+func (e *engine) removePlannedPackages(packages []string) error {
+	sort.Strings(packages)
+
+	for _, pack := range packages {
+		if err := e.ctx.Err(); err != nil {
+			return errors.Trace(err)
+		}
+
+		if e.dryRun {
+			step := e.planPackageRemoval(pack)
+
+			e.reporter.Message(MessageWarning, fmt.Sprintf("package %s ... would be removed", pack))
+			for _, removePath := range step.Paths {
+				e.reporter.Message(MessageInfo, fmt.Sprintf("  - would remove %s", removePath))
+			}
+
+			continue
+		}
+
+		step := Step{Action: "removing package", Subject: pack}
+		e.reporter.Begin(step)
+
+		err := e.removePackage(pack)
+		e.reportStep(step, err)
+
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	return nil
+}
+
+func (e *engine) removePackages(packages []string, bunch *BunchFile, removeGlobally bool) error {
+	if !removeGlobally {
+		err := e.useVendor()
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	toRemove, err := e.planRemovePackages(packages, bunch)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return e.removePlannedPackages(toRemove)
+}
+
+func (e *engine) planRemovePackages(packages []string, bunch *BunchFile) ([]string, error) {
+	gopath := e.gopath
+
+	allPackages := make(map[string]bool)
+	packagesUsed := make(map[string][]string)
+
+	combinedPackagesList := packages
+	for _, packData := range bunch.Packages {
+		combinedPackagesList = append(combinedPackagesList, packData.Repo)
+	}
+
+	for _, pack := range combinedPackagesList {
+		if exists, _ := pathExists(path.Join(gopath, "src", pack)); !exists {
+			continue
+		}
+
+		goListCommand := []string{"go", "list", "--json", pack}
+		output, err := e.commandOutput(e.command("", goListCommand[0], goListCommand[1:]...))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		packageInfo := GoList{}
+		err = json.Unmarshal(output, &packageInfo)
+
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		removingPackage := false
+		for _, removePack := range packages {
+			if removePack == pack {
+				removingPackage = true
+			}
+		}
+
+		if !removingPackage {
+			packagesUsed[pack] = append(packagesUsed[pack], "app")
+		}
+
+		for _, dep := range packageInfo.Deps {
+			srcPath := path.Join(gopath, "src", dep)
+			if exists, _ := pathExists(srcPath); exists {
+				allPackages[dep] = true
+
+				if !removingPackage {
+					packagesUsed[dep] = append(packagesUsed[dep], pack)
+				}
+			}
+		}
+
+		allPackages[pack] = true
+	}
+
+	for _, pack := range packages {
+		if len(packagesUsed[pack]) > 0 {
+			e.reporter.Message(MessageError, fmt.Sprintf("unable to remove package %s, is depended on by %s", pack, strings.Join(packagesUsed[pack], ", ")))
+		}
+	}
+
+	toRemove := []string{}
+
+	for pack, _ := range allPackages {
+		if len(packagesUsed[pack]) == 0 {
+			toRemove = append(toRemove, pack)
+		}
+	}
+
+	return toRemove, nil
+}
+
+func isRootPackageUsed(packagesUsed map[string]bool, packName string) bool {
+	for pack, _ := range packagesUsed {
+		if strings.HasPrefix(pack, packName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *engine) prunePackages(bunch *BunchFile) error {
+	err := e.useVendor()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	toRemove, err := e.planPrunePackages(bunch)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return e.removePlannedPackages(toRemove)
+}
+
+func (e *engine) planPrunePackages(bunch *BunchFile) ([]string, error) {
+	gopath := e.gopath
+
+	packagesUsed := make(map[string]bool)
+
+	for _, packInfo := range bunch.Packages {
+		pack := packInfo.Repo
+
+		if exists, _ := pathExists(path.Join(gopath, "src", pack)); !exists {
+			continue
+		}
+
+		goListCommand := []string{"go", "list", "--json", pack}
+		output, err := e.commandOutput(e.command("", goListCommand[0], goListCommand[1:]...))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		packageInfo := GoList{}
+		err = json.Unmarshal(output, &packageInfo)
+
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		packagesUsed[pack] = true
+
+		for _, dep := range packageInfo.Deps {
+			srcPath := path.Join(gopath, "src", dep)
+			if exists, _ := pathExists(srcPath); exists {
+				packagesUsed[dep] = true
+			}
+		}
+	}
+
+	srcDir := path.Join(gopath, "src")
+
+	packFiles := []string{}
+	err := filepath.Walk(srcDir, func(walkPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		gitExists, _ := pathExists(path.Join(walkPath, ".git"))
+		hgExists, _ := pathExists(path.Join(walkPath, ".hg"))
+		bzrExists, _ := pathExists(path.Join(walkPath, ".bzr"))
+
+		if gitExists || hgExists || bzrExists {
+			packPath, err := filepath.Rel(srcDir, walkPath)
+			if err != nil {
+				return err
+			}
+
+			packFiles = append(packFiles, filepath.ToSlash(packPath))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	toRemove := []string{}
+
+	for _, pack := range packFiles {
+		if !packagesUsed[pack] && !isRootPackageUsed(packagesUsed, pack) {
+			toRemove = append(toRemove, pack)
+		}
+	}
+
+	return toRemove, nil
+}
+
+// ShortCommit abbreviates a commit hash the way git log --oneline does
+func ShortCommit(fullhash string) string {
+	if len(fullhash) < 8 {
+		return fullhash
+	} else {
+		return fullhash[:7]
+	}
+}
+
+func orDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+
+	return value
+}
+
+// CountCommits says how many commits n is, as in "1 commit" or "3 commits"
+func CountCommits(n int) string {
+	if n == 1 {
+		return "1 commit"
+	} else {
+		return fmt.Sprintf("%d commits", n)
+	}
+}
+
+func outdatedStatus(pack Package, needsUpdate bool, recency PackageRecencyInfo) (string, int, string) { // status, commits behind, target commit
+	if !needsUpdate {
+		if recency.UpstreamDiffCount == 0 {
+			return "up to date", 0, ""
+		} else if pack.LockedVersion == "" {
+			return "behind upstream", recency.UpstreamDiffCount, recency.LatestUpstreamCommit
+		} else {
+			return "locked, but behind upstream", recency.UpstreamDiffCount, recency.LatestUpstreamCommit
+		}
+	} else {
+		if recency.InstalledDiffCount == 0 {
+			return "up to date", 0, ""
+		} else if pack.LockedVersion == "" {
+			return "outdated", recency.InstalledDiffCount, recency.LatestCommit
+		} else {
+			return "locked, but outdated", recency.InstalledDiffCount, recency.LatestCommit
+		}
+	}
+}
+
+func (e *engine) checkOutdatedPackages(b *BunchFile) ([]OutdatedPackage, error) {
+	err := e.useVendor()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	report := []OutdatedPackage{}
+
+	for _, pack := range b.withBuildDefaults() {
+		if pack.IsSelf {
+			continue
+		}
+
+		if err := e.ctx.Err(); err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		step := Step{Action: "fetching", Subject: pack.Repo, Verbose: true}
+		e.reporter.Begin(step)
+
+		err := e.fetchPackage(pack.Repo)
+		e.reportStep(step, err)
+
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		needsUpdate, recency, err := e.checkPackageRecency(pack)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		status, commitsBehind, targetCommit := outdatedStatus(pack, needsUpdate, recency)
+
+		report = append(report, OutdatedPackage{
+			Repo:               pack.Repo,
+			Version:            pack.Version,
+			LockedVersion:      pack.LockedVersion,
+			Locked:             pack.LockedVersion != "",
+			NeedsUpdate:        needsUpdate,
+			Status:             status,
+			CommitsBehind:      commitsBehind,
+			TargetCommit:       targetCommit,
+			PackageRecencyInfo: recency,
+		})
+	}
+
+	return report, nil
+}
+
+func (e *engine) listPackages(b *BunchFile) ([]ListedPackage, error) {
+	err := e.useVendor()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	gopath := e.gopath
+
+	report := []ListedPackage{}
+
+	for _, pack := range b.Packages {
+		installed, _ := pathExists(path.Join(gopath, "src", getRealRepoPath(pack.Repo)))
+
+		listed := ListedPackage{
+			Repo:          pack.Repo,
+			Version:       pack.Version,
+			LockedVersion: pack.LockedVersion,
+			Installed:     installed,
+			IsSelf:        pack.IsSelf,
+			IsLink:        pack.IsLink,
+			LinkTarget:    pack.LinkTarget,
+		}
+
+		if installed && !pack.IsLink {
+			listed.InstalledCommit, err = e.getInstalledRevision(pack.Repo)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+
+		report = append(report, listed)
+	}
+
+	return report, nil
+}
+
+func (e *engine) lockPackages(b *BunchFile) error {
+	err := e.useVendor()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	lockList := make(map[string]string)
+
+	for _, pack := range b.Packages {
+		if pack.IsLink {
+			continue
+		}
+
+		if err := e.ctx.Err(); err != nil {
+			return errors.Trace(err)
+		}
+
+		_, recency, err := e.checkPackageRecency(pack)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		lockList[pack.Repo] = recency.LatestCommit
+	}
+
+	jsonOut, err := json.MarshalIndent(lockList, "", "    ")
+	if err != nil {
+		return errors.Trace(err)
+	} else {
+		err = writeFileAtomic(e.bunchfileLockPath(), append(jsonOut, '\n'), 0644)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		e.reporter.Message(MessageSuccess, "Bunchfile.lock generated successfully")
+	}
+
+	return nil
+}
@@ -0,0 +1,241 @@
+package bunch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathExists(t *testing.T) {
+	exists, err := pathExists("/")
+	assert.Nil(t, err, "path exists should not error out")
+
+	assert.Equal(t, exists, true, "/ path should exist")
+}
+
+func TestCountNonEmptyString(t *testing.T) {
+	emptiesGalore := []string{"a", "b", "", "c", "", "", "d", ""}
+	noEmpties := []string{"a", "b", "c"}
+
+	assert.Equal(t, 4, countNonEmptyStrings(emptiesGalore), "should count 4 non-empties")
+	assert.Equal(t, 3, countNonEmptyStrings(noEmpties), "should count 3 non-empties")
+}
+
+func TestParsePackage(t *testing.T) {
+	pack1 := parsePackage("github.com/a/b/c", "github.com")
+	pack2 := parsePackage("github.com/a/b/c@v1.2.0", "github.com")
+	pack3 := parsePackage("a/b", "github.com")
+	pack4 := parsePackage("gopkg.in/abc", "github.com")
+
+	assert.Equal(t, pack1.Repo, "github.com/a/b/c", "package repo should equal")
+	assert.Equal(t, pack2.Repo, "github.com/a/b/c", "package repo should equal")
+
+	assert.Equal(t, pack1.Version, "", "package version should be unset")
+	assert.Equal(t, pack2.Version, "v1.2.0", "package version should be set")
+
+	assert.Equal(t, pack3.Repo, "github.com/a/b", "github shorthand should have been expanded")
+	assert.Equal(t, pack4.Repo, "gopkg.in/abc", "package containing domain should not have been expanded")
+}
+
+func TestOutdatedStatus(t *testing.T) {
+	unlocked := Package{Repo: "github.com/a/b"}
+	locked := Package{Repo: "github.com/a/b", LockedVersion: "abc123"}
+
+	recency := PackageRecencyInfo{LatestCommit: "def", LatestUpstreamCommit: "ghi", UpstreamDiffCount: 3, InstalledDiffCount: 2}
+
+	status, count, target := outdatedStatus(unlocked, false, recency)
+	assert.Equal(t, "behind upstream", status, "package should be behind upstream")
+	assert.Equal(t, 3, count, "should count upstream commits")
+	assert.Equal(t, "ghi", target, "target should be upstream commit")
+
+	status, count, target = outdatedStatus(locked, true, recency)
+	assert.Equal(t, "locked, but outdated", status, "locked package should be outdated")
+	assert.Equal(t, 2, count, "should count installed commits")
+	assert.Equal(t, "def", target, "target should be wanted commit")
+
+	status, count, _ = outdatedStatus(unlocked, false, PackageRecencyInfo{})
+	assert.Equal(t, "up to date", status, "package should be up to date")
+	assert.Equal(t, 0, count, "should count no commits")
+}
+
+/*
+  pack1 := parsePackage("github.com/a/b/c")
+  pack2 := parsePackage("github.com/a/b/c !self")
+  pack3 := parsePackage("github.com/a/b/c !link:/tmp")
+  pack4 := parsePackage("github.com/a/b/c v1.2.0")
+  pack5 := parsePackage("github.com/a/b/c    master")
+
+  assert.Equal(t, pack1.Repo, "github.com/a/b/c", "package repo should equal")
+  assert.Equal(t, pack2.Repo, "github.com/a/b/c", "package repo should equal")
+  assert.Equal(t, pack3.Repo, "github.com/a/b/c", "package repo should equal")
+  assert.Equal(t, pack4.Repo, "github.com/a/b/c", "package repo should equal")
+  assert.Equal(t, pack5.Repo, "github.com/a/b/c", "package repo should equal")
+
+  assert.Equal(t, pack1.Version, "", "package version should be unset")
+  assert.Equal(t, pack4.Version, "v1.2.0", "package version should be set")
+  assert.Equal(t, pack5.Version, "master", "package version should be set")
+
+  assert.Equal(t, pack2.IsSelf, true, "package should be marked isself")
+  assert.Equal(t, pack2.IsLink, true, "package should be marked islink")
+  assert.Equal(t, pack3.IsLink, true, "package should be marked islink")
+  assert.NotEqual(t, pack2.LinkTarget, "", "package should have link target set")
+  assert.NotEqual(t, pack3.LinkTarget, "", "package should have link target set")
+*/
+
+func TestRemovePackageCleansAllTargets(t *testing.T) {
+	e, cleanup := withTempGopath(t)
+	defer cleanup()
+
+	dir := e.gopath
+
+	artifacts := []string{
+		"src/github.com/a/tool/main.go",
+		"pkg/linux_amd64/github.com/a/tool.a",
+		"pkg/linux_arm64/github.com/a/tool/sub.a",
+		"bin/tool",
+		"bin/windows_amd64/tool.exe",
+		"pkg/mod/github.com/a/tool.a",
+	}
+	for _, artifact := range artifacts {
+		_ = os.MkdirAll(path.Dir(path.Join(dir, artifact)), 0755)
+		_ = ioutil.WriteFile(path.Join(dir, artifact), []byte{}, 0644)
+	}
+
+	err := e.removePackage("github.com/a/tool")
+	assert.Nil(t, err, "package should be removed")
+
+	for _, artifact := range artifacts[:5] {
+		exists, _ := pathExists(path.Join(dir, artifact))
+		assert.False(t, exists, "%s should be removed", artifact)
+	}
+
+	exists, _ := pathExists(path.Join(dir, artifacts[5]))
+	assert.True(t, exists, "the module cache should be left alone")
+}
+
+func TestFetchPackagesInParallel(t *testing.T) {
+	e, cleanup := withTempGopath(t)
+	defer cleanup()
+
+	gopath := e.gopath
+	for _, repo := range []string{"example.com/a/one", "example.com/b/two", "example.com/c/three"} {
+		_ = os.MkdirAll(path.Join(gopath, "src", repo), 0755)
+	}
+
+	err := e.fetchPackagesInParallel([]string{"example.com/a/one", "example.com/b/two", "example.com/c/three"}, 2)
+	assert.Nil(t, err, "packages without a vcs dir should be skipped")
+
+	// a repository git can't pull fails without the others' cancellation hiding its error
+	broken := path.Join(gopath, "src", "example.com/b/two")
+	_ = os.MkdirAll(path.Join(broken, ".git"), 0755)
+
+	err = e.fetchPackagesInParallel([]string{"example.com/a/one", "example.com/b/two", "example.com/c/three"}, 2)
+	assert.NotNil(t, err, "the failed fetch should be returned")
+	assert.Contains(t, err.Error(), "example.com/b/two", "error should name the failed package")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e.ctx = ctx
+
+	err = e.fetchPackagesInParallel([]string{"example.com/a/one"}, 2)
+	assert.Equal(t, context.Canceled, errors.Cause(err), "cancelling should stop the fetch")
+}
+
+func TestCheckCleanCheckout(t *testing.T) {
+	e, cleanup := withTempGopath(t)
+	defer cleanup()
+
+	repoDir := path.Join(e.gopath, "src", "example.com/a/lib")
+	_ = os.MkdirAll(repoDir, 0755)
+	gitIn(t, repoDir, "init", "-q")
+	_ = ioutil.WriteFile(path.Join(repoDir, "lib.go"), []byte("package lib\n"), 0644)
+	gitIn(t, repoDir, "add", "lib.go")
+	gitIn(t, repoDir, "commit", "-q", "-m", "first")
+	gitIn(t, repoDir, "commit", "-q", "--allow-empty", "-m", "second")
+
+	assert.Nil(t, e.checkCleanCheckout("example.com/a/lib", repoDir, ".git", "HEAD~1"), "clean checkouts can be moved")
+
+	_ = ioutil.WriteFile(path.Join(repoDir, "lib.go"), []byte("package lib // changed\n"), 0644)
+
+	err := e.checkCleanCheckout("example.com/a/lib", repoDir, ".git", "HEAD~1")
+	assert.Equal(t, KindDirtyVendor, KindOf(err), "local changes shouldn't be clobbered")
+	assert.Nil(t, e.checkCleanCheckout("example.com/a/lib", repoDir, ".git", "HEAD"), "staying at the same revision keeps local changes")
+
+	_ = os.RemoveAll(path.Join(repoDir, ".git"))
+	_ = os.Mkdir(path.Join(repoDir, ".git"), 0755)
+
+	err = e.checkCleanCheckout("example.com/a/lib", repoDir, ".git", "HEAD~1")
+	assert.NotNil(t, err, "a repository git can't read shouldn't pass as clean")
+	assert.NotEqual(t, KindDirtyVendor, KindOf(err), "failing to check isn't the same as having local changes")
+}
+
+func TestVerifyLockedRevisionFetches(t *testing.T) {
+	e, cleanup := withTempGopath(t)
+	defer cleanup()
+
+	upstream := path.Join(e.gopath, "upstream")
+	repoDir := path.Join(e.gopath, "src", "example.com/a/lib")
+
+	_ = os.MkdirAll(upstream, 0755)
+	gitIn(t, upstream, "init", "-q")
+	gitIn(t, upstream, "commit", "-q", "--allow-empty", "-m", "first")
+	_ = os.MkdirAll(path.Dir(repoDir), 0755)
+	gitIn(t, upstream, "clone", "-q", upstream, repoDir)
+
+	gitIn(t, upstream, "commit", "-q", "--allow-empty", "-m", "pushed after the last fetch")
+	locked := gitIn(t, upstream, "rev-parse", "HEAD")
+
+	err := e.verifyLockedRevision(Package{Repo: "example.com/a/lib", LockedVersion: locked})
+	assert.Nil(t, err, "a locked commit newer than the last fetch should be fetched")
+
+	err = e.verifyLockedRevision(Package{Repo: "example.com/a/lib", LockedVersion: "0123456789abcdef0123456789abcdef01234567"})
+	assert.Equal(t, KindLockMismatch, KindOf(err), "a commit upstream doesn't have either is a lock mismatch")
+	assert.Contains(t, err.Error(), "bunch install", "error should say how to recover")
+}
+
+func TestDryRunInstallPlan(t *testing.T) {
+	color.NoColor = true
+
+	e, cleanup := withTempGopath(t)
+	defer cleanup()
+
+	_, repoDir := cloneTaggedRepo(t, e, "example.com/a/lib", "v1.0.0", "v1.1.0")
+	gitIn(t, repoDir, "checkout", "-q", "v1.0.0")
+	installed := gitIn(t, repoDir, "rev-parse", "HEAD")
+
+	var out bytes.Buffer
+	e.reporter = NewPlainReporter(&out, false)
+	e.dryRun = true
+
+	plan, err := e.planInstall([]Package{{Repo: "example.com/a/lib", Version: ">= 1.1"}, {Repo: "example.com/a/missing"}}, false, true, true)
+	assert.Nil(t, err, "plan should be made")
+	assert.Equal(t, 2, len(plan.Steps), "every package should have a step")
+
+	lib, missing := plan.Steps[0], plan.Steps[1]
+	assert.True(t, lib.Install && lib.Fetch, "outdated package should be fetched and installed")
+	assert.Equal(t, installed, lib.PreviousRevision, "installed revision should be recorded")
+	assert.Equal(t, gitIn(t, repoDir, "rev-parse", "v1.1.0"), lib.Target, "dry runs should resolve the target")
+	assert.True(t, missing.Install, "missing package should be installed")
+	assert.Equal(t, "", missing.PreviousRevision, "missing package has no installed revision")
+
+	assert.Nil(t, e.reportInstallPlan(plan), "plan should be reported")
+
+	output := out.String()
+	assert.Contains(t, output, fmt.Sprintf("package example.com/a/lib ... would update %s -> %s (1 commit, 1 file changed)", ShortCommit(installed), ShortCommit(lib.Target)), "update should be previewed")
+	assert.Contains(t, output, "release v1.1.0", "changelog should be shown")
+	assert.Contains(t, output, "package example.com/a/missing ... would be fetched and checked out at the default branch", "missing package should be described")
+	assert.Contains(t, output, "would build and install example.com/a/lib", "builds should be described")
+
+	assert.Equal(t, installed, gitIn(t, repoDir, "rev-parse", "HEAD"), "dry runs should leave the checkout alone")
+
+	exists, _ := pathExists(path.Join(e.gopath, "src", "example.com/a/missing"))
+	assert.False(t, exists, "dry runs shouldn't clone missing packages")
+}
@@ -0,0 +1,344 @@
+package bunch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/fatih/color"
+)
+
+type StepResult int
+
+const (
+	StepDone StepResult = iota
+	StepSkipped
+	StepFailed
+)
+
+var stepResultNames = map[StepResult]string{StepDone: "done", StepSkipped: "skipped", StepFailed: "failed"}
+
+func (r StepResult) String() string {
+	return stepResultNames[r]
+}
+
+type MessageKind int
+
+const (
+	MessageInfo MessageKind = iota
+	MessageSuccess
+	MessageWarning
+	MessageError
+)
+
+var messageKindNames = map[MessageKind]string{MessageInfo: "info", MessageSuccess: "success", MessageWarning: "warning", MessageError: "error"}
+
+func (k MessageKind) String() string {
+	return messageKindNames[k]
+}
+
+// Step is a unit of work reported while it runs, e.g. fetching or building one package
+type Step struct {
+	Action  string // e.g. "fetching", "building package"
+	Subject string // the package or repository worked on
+	Detail  string // e.g. the version being checked out
+	Verbose bool   // a sub-step of a package's install, only shown in verbose mode
+}
+
+func (s Step) String() string {
+	if s.Detail == "" {
+		return fmt.Sprintf("%s %s", s.Action, s.Subject)
+	}
+
+	return fmt.Sprintf("%s %s %s", s.Action, s.Subject, s.Detail)
+}
+
+// Reporter shows the progress of an operation. Steps of parallel fetches run concurrently,
+// so implementations must be safe for concurrent use. End is called with the same Step
+// that was passed to Begin; note, if not empty, replaces the result in the output.
+type Reporter interface {
+	Begin(step Step)
+	End(step Step, result StepResult, note string)
+	Message(kind MessageKind, text string)
+}
+
+func (e *engine) reportStep(step Step, err error) { // ends step as done or failed depending on err
+	if err != nil {
+		e.reporter.End(step, StepFailed, "")
+	} else {
+		e.reporter.End(step, StepDone, "")
+	}
+}
+
+type nopReporter struct{}
+
+func (nopReporter) Begin(step Step)                               {}
+func (nopReporter) End(step Step, result StepResult, note string) {}
+func (nopReporter) Message(kind MessageKind, text string)         {}
+
+func colorResult(result StepResult, text string) string {
+	switch result {
+	case StepSkipped:
+		return color.YellowString(text)
+	case StepFailed:
+		return color.RedString(text)
+	}
+
+	return color.GreenString(text)
+}
+
+func colorMessage(kind MessageKind, text string) string {
+	switch kind {
+	case MessageSuccess:
+		return color.GreenString(text)
+	case MessageWarning:
+		return color.YellowString(text)
+	case MessageError:
+		return color.RedString(text)
+	}
+
+	return text
+}
+
+func stepLine(step Step) string {
+	if step.Verbose {
+		return "  - " + step.String()
+	}
+
+	return step.String()
+}
+
+func endLine(step Step, result StepResult, note string) string {
+	return fmt.Sprintf("%s ... %s", stepLine(step), colorResult(result, orDefault(note, result.String())))
+}
+
+// plainReporter writes one line per finished step, for logs and other non-terminals
+type plainReporter struct {
+	out     io.Writer
+	verbose bool
+	mutex   sync.Mutex
+}
+
+func NewPlainReporter(out io.Writer, verbose bool) Reporter {
+	return &plainReporter{out: out, verbose: verbose}
+}
+
+func (r *plainReporter) Begin(step Step) {
+	if !r.verbose {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	fmt.Fprintf(r.out, "%s ...\n", stepLine(step))
+}
+
+func (r *plainReporter) End(step Step, result StepResult, note string) {
+	if step.Verbose && !r.verbose {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	fmt.Fprintln(r.out, endLine(step, result, note))
+}
+
+func (r *plainReporter) Message(kind MessageKind, text string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	fmt.Fprintln(r.out, colorMessage(kind, text))
+}
+
+// ttyReporter keeps a line per running step at the bottom of the terminal, redrawing them
+// in place (with a spinner if animated) and printing a step above them once it ends
+type ttyReporter struct {
+	out     io.Writer
+	verbose bool
+	animate bool
+	frames  []string
+
+	mutex   sync.Mutex
+	active  []Step
+	drawn   int // lines of running steps currently on screen
+	frame   int
+	ticking bool
+}
+
+func NewTTYReporter(out io.Writer, verbose bool, animate bool) Reporter {
+	return &ttyReporter{out: out, verbose: verbose, animate: animate, frames: spinner.CharSets[spinnerCharSet]}
+}
+
+func (r *ttyReporter) shown(step Step) bool {
+	return r.verbose || !step.Verbose
+}
+
+func (r *ttyReporter) clear() {
+	if r.drawn > 0 {
+		fmt.Fprintf(r.out, "\x1b[%dA\x1b[J", r.drawn)
+		r.drawn = 0
+	}
+}
+
+func (r *ttyReporter) draw() {
+	for _, step := range r.active {
+		if r.animate {
+			fmt.Fprintf(r.out, "%s %s\n", stepLine(step), color.GreenString(r.frames[r.frame%len(r.frames)]))
+		} else {
+			fmt.Fprintf(r.out, "%s ...\n", stepLine(step))
+		}
+	}
+
+	r.drawn = len(r.active)
+}
+
+func (r *ttyReporter) tick() {
+	for {
+		time.Sleep(spinnerInterval)
+
+		r.mutex.Lock()
+
+		if len(r.active) == 0 {
+			r.ticking = false
+			r.mutex.Unlock()
+			return
+		}
+
+		r.frame++
+		r.clear()
+		r.draw()
+
+		r.mutex.Unlock()
+	}
+}
+
+func (r *ttyReporter) Begin(step Step) {
+	if !r.shown(step) {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.clear()
+	r.active = append(r.active, step)
+	r.draw()
+
+	if r.animate && !r.ticking {
+		r.ticking = true
+		go r.tick()
+	}
+}
+
+func (r *ttyReporter) End(step Step, result StepResult, note string) {
+	if !r.shown(step) {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.active {
+		if r.active[i] == step {
+			r.active = append(r.active[:i], r.active[i+1:]...)
+			break
+		}
+	}
+
+	r.clear()
+	fmt.Fprintln(r.out, endLine(step, result, note))
+	r.draw()
+}
+
+func (r *ttyReporter) Message(kind MessageKind, text string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.clear()
+	fmt.Fprintln(r.out, colorMessage(kind, text))
+	r.draw()
+}
+
+type progressEvent struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"` // begin, end or message
+	Action  string    `json:"action,omitempty"`
+	Subject string    `json:"subject,omitempty"`
+	Detail  string    `json:"detail,omitempty"`
+	Verbose bool      `json:"verbose,omitempty"`
+	Result  string    `json:"result,omitempty"`
+	Note    string    `json:"note,omitempty"`
+	Kind    string    `json:"kind,omitempty"`
+	Text    string    `json:"text,omitempty"`
+}
+
+// jsonReporter writes every event, including verbose ones, as a JSON object per line
+type jsonReporter struct {
+	encoder *json.Encoder
+	mutex   sync.Mutex
+}
+
+func NewJSONReporter(out io.Writer) Reporter {
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+
+	return &jsonReporter{encoder: encoder}
+}
+
+func (r *jsonReporter) emit(event progressEvent) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	event.Time = time.Now().UTC()
+	_ = r.encoder.Encode(event)
+}
+
+func (r *jsonReporter) Begin(step Step) {
+	r.emit(progressEvent{Event: "begin", Action: step.Action, Subject: step.Subject, Detail: step.Detail, Verbose: step.Verbose})
+}
+
+func (r *jsonReporter) End(step Step, result StepResult, note string) {
+	r.emit(progressEvent{Event: "end", Action: step.Action, Subject: step.Subject, Detail: step.Detail, Verbose: step.Verbose, Result: result.String(), Note: note})
+}
+
+func (r *jsonReporter) Message(kind MessageKind, text string) {
+	r.emit(progressEvent{Event: "message", Kind: kind.String(), Text: text})
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb"
+}
+
+// NewReporter returns the reporter named by the progress setting: tty, plain, json (written to
+// stderr, so it doesn't mix with --json output), none, or auto, which picks tty or plain
+// depending on whether stdout is a terminal
+func NewReporter(name string, verbose bool, animate bool) Reporter {
+	switch strings.ToLower(name) {
+	case "tty":
+		return NewTTYReporter(os.Stdout, verbose, animate)
+	case "plain":
+		return NewPlainReporter(os.Stdout, verbose)
+	case "json":
+		return NewJSONReporter(os.Stderr)
+	case "none":
+		return nopReporter{}
+	}
+
+	if isTerminal(os.Stdout) {
+		return NewTTYReporter(os.Stdout, verbose, animate)
+	}
+
+	return NewPlainReporter(os.Stdout, verbose)
+}
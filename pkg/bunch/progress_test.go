@@ -0,0 +1,77 @@
+package bunch
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlainReporter(t *testing.T) {
+	color.NoColor = true
+
+	var out bytes.Buffer
+	r := NewPlainReporter(&out, false)
+
+	fetch := Step{Action: "fetching", Subject: "github.com/a/b"}
+	build := Step{Action: "building package", Subject: "github.com/a/b", Verbose: true}
+
+	r.Begin(fetch)
+	r.Begin(build)
+	r.End(build, StepDone, "")
+	r.End(fetch, StepSkipped, "skipped, up to date")
+	r.Message(MessageWarning, "install failed, rolling back")
+
+	assert.Equal(t, "fetching github.com/a/b ... skipped, up to date\ninstall failed, rolling back\n", out.String(), "only finished top-level steps and messages should be printed")
+
+	out.Reset()
+	r = NewPlainReporter(&out, true)
+
+	r.Begin(build)
+	r.End(build, StepFailed, "")
+
+	assert.Equal(t, "  - building package github.com/a/b ...\n  - building package github.com/a/b ... failed\n", out.String(), "verbose mode should print sub-steps as they start and end")
+}
+
+func TestTTYReporter(t *testing.T) {
+	color.NoColor = true
+
+	var out bytes.Buffer
+	r := NewTTYReporter(&out, false, false)
+
+	first := Step{Action: "fetching", Subject: "github.com/a/b"}
+	second := Step{Action: "fetching", Subject: "github.com/c/d"}
+
+	r.Begin(first)
+	r.Begin(second)
+	r.End(first, StepDone, "")
+	r.End(second, StepDone, "")
+
+	expected := "fetching github.com/a/b ...\n" +
+		"\x1b[1A\x1b[Jfetching github.com/a/b ...\nfetching github.com/c/d ...\n" +
+		"\x1b[2A\x1b[Jfetching github.com/a/b ... done\nfetching github.com/c/d ...\n" +
+		"\x1b[1A\x1b[Jfetching github.com/c/d ... done\n"
+
+	assert.Equal(t, expected, out.String(), "running steps should be redrawn below finished ones")
+}
+
+func TestJSONReporter(t *testing.T) {
+	var out bytes.Buffer
+	r := NewJSONReporter(&out)
+
+	step := Step{Action: "building package", Subject: "github.com/a/b", Verbose: true}
+	r.Begin(step)
+	r.End(step, StepFailed, "")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, 2, len(lines), "every event should be written, verbose or not")
+
+	event := progressEvent{}
+	assert.Nil(t, json.Unmarshal([]byte(lines[1]), &event), "events should be JSON")
+	assert.Equal(t, "end", event.Event, "event type should be recorded")
+	assert.Equal(t, "github.com/a/b", event.Subject, "subject should be recorded")
+	assert.Equal(t, "failed", event.Result, "result should be recorded")
+}
@@ -0,0 +1,575 @@
+// Package bunch reads Bunchfiles and installs, updates, upgrades and prunes the
+// dependencies they list into a project's vendor directory. The bunch command is
+// a thin wrapper around it.
+//
+// Operations leave the process environment and working directory alone: the commands
+// they run get the vendor GOPATH and PATH through their own environment, so several
+// may run at once. Operations on the same vendor directory wait for each other.
+package bunch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+var spinnerCharSet = 14
+var spinnerInterval = 50 * time.Millisecond
+
+// engine is what one operation works with: the project's paths and settings, the environment its
+// commands run with and what it has looked up so far. Every Project method makes its own, so
+// nothing is shared between operations but the vendor lock.
+type engine struct {
+	ctx context.Context // cancels the commands of the operation
+
+	projectRoot string
+	vendorDir   string
+
+	gopath        string   // packages are read from and installed into gopath/src: the caller's GOPATH until useVendor
+	env           []string // commands run with this; GOPATH and PATH point into the vendor dir after useVendor
+	userEnv       []string // the process environment, with the proxy settings of the config files
+	initialPath   string
+	initialGoPath string
+
+	verbose  bool
+	dryRun   bool
+	readOnly bool // the operation only reports on the vendor tree (outdated, diff) and mustn't change it
+
+	parallelism    int
+	defaultHost    string
+	networkTimeout time.Duration
+	networkRetries int
+	lockTimeout    time.Duration
+	mirrorRules    []MirrorRule
+	goToolchains   string   // directory of installed Go versions, e.g. ~/sdk with go1.21.5/bin/go in it
+	buildTargets   []Target // built for besides the host, set by installs with targets
+
+	reporter Reporter
+	runLog   *commandLog // nil when not logging, e.g. during dry runs
+
+	goToolchain          *resolvedToolchain // resolved once per operation
+	toolchainFingerprint *buildFingerprint  // go version and target, looked up once per operation
+	activeSnapshot       *VendorSnapshot    // of the running install, which clones record themselves in
+}
+
+// Project is a directory with a Bunchfile (or the directory a Bunchfile would be
+// created in) together with the settings used to work on it. Open fills the
+// settings from the config files; callers may change them before calling a method.
+type Project struct {
+	Root      string
+	VendorDir string
+	Config    *Config
+
+	Verbose     bool   // print progress for each package
+	Quiet       bool   // print nothing, results are only returned
+	DryRun      bool   // report what would change without changing anything
+	Spinner     bool   // animate running steps on terminals
+	Progress    string // reporter used if Reporter is nil: auto, tty, plain, json or none
+	Reporter    Reporter
+	Parallelism int
+	DefaultHost string
+	LockTimeout time.Duration
+	Mirrors     []MirrorRule
+
+	NetworkTimeout time.Duration // limit for each clone, fetch or remote lookup; 0 for none
+	NetworkRetries int           // retries of failed network operations, with exponential backoff
+
+	GoToolchains string // directory of installed Go versions to find the Bunchfile's !go version in
+}
+
+type InstallOptions struct {
+	Packages      []string // installed instead of the Bunchfile's packages if given, e.g. github.com/abc/xyz@v1.2
+	ForceUpdate   bool     // reinstall packages even if they're up to date (update, rebuild)
+	CheckUpstream bool     // fetch from remotes; rebuild doesn't
+	RespectLocked bool     // use the revisions in Bunchfile.lock; update doesn't
+	Global        bool     // install into $GOPATH instead of the vendor directory
+	Save          bool     // add Packages to the Bunchfile
+	Targets       []Target // also build packages for these platforms, e.g. to cross-compile
+}
+
+type InstallResult struct {
+	Plan   InstallPlan
+	Report *InstallReport // nil for dry runs
+}
+
+type UpgradeOptions struct {
+	Mode   UpgradeMode
+	Choose UpgradeChooser // picks the new version of each package for UpgradeInteractive
+}
+
+type UninstallOptions struct {
+	Global bool // remove from $GOPATH instead of the vendor directory
+	Save   bool // remove the packages from the Bunchfile
+}
+
+// Open finds the project containing dir by walking upward to the nearest
+// Bunchfile (dir itself if there is none) and reads its settings.
+func Open(dir string) (*Project, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	root, _ := findProjectRoot(dir)
+
+	config, err := LoadConfig(root)
+	if err != nil {
+		return nil, errors.Annotate(err, "unable to read config")
+	}
+
+	mirrors, err := parseMirrorRules(config.Get("mirror"))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &Project{
+		Root:        root,
+		VendorDir:   resolveVendorDir(root, config.Get("vendor_dir")),
+		Config:      config,
+		Spinner:     config.GetBool("spinner"),
+		Progress:    config.Get("progress"),
+		Parallelism: config.GetInt("parallelism"),
+		DefaultHost: config.Get("default_host"),
+		LockTimeout: config.GetDuration("lock_timeout"),
+		Mirrors:     mirrors,
+
+		NetworkTimeout: config.GetDuration("network_timeout"),
+		NetworkRetries: config.GetInt("network_retries"),
+
+		GoToolchains: config.Get("go_toolchains"),
+	}, nil
+}
+
+func (p *Project) HasBunchfile() bool {
+	exists, _ := pathExists(path.Join(p.Root, "Bunchfile"))
+	return exists
+}
+
+// VendorEnv returns the process environment with GOPATH and PATH pointing into the vendor
+// directory, for running go and other tools against vendored packages. The Go version the
+// Bunchfile's !go directive asks for comes first on PATH if it is installed in GoToolchains; it
+// fails if the go on PATH isn't that version.
+func (p *Project) VendorEnv() ([]string, error) {
+	e := p.newEngine(context.Background())
+
+	err := e.useVendor()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return e.env, nil
+}
+
+// GoEnv is VendorEnv with the Bunchfile's default build settings added, for running go commands:
+// its !env variables, and its !tags and !ldflags in GOFLAGS
+func (p *Project) GoEnv() ([]string, error) {
+	env, err := p.VendorEnv()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	bunch, err := p.Bunchfile()
+	if err == ErrNoBunchfile {
+		return env, nil
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	flags, err := bunch.Defaults.goFlags()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if len(flags) > 0 {
+		if goflags, _ := lookupEnv(env, "GOFLAGS"); goflags != "" {
+			flags = append([]string{goflags}, flags...)
+		}
+		env = setEnv(env, "GOFLAGS="+strings.Join(flags, " "))
+	}
+
+	return setEnv(env, bunch.Defaults.Env...), nil
+}
+
+func (p *Project) newEngine(ctx context.Context) *engine { // an engine working on this project with its current settings
+	e := &engine{
+		ctx:         ctx,
+		projectRoot: p.Root,
+		vendorDir:   p.VendorDir,
+
+		verbose: p.Verbose && !p.Quiet, // spinners and progress lines would corrupt machine-readable output
+		dryRun:  p.DryRun,
+
+		parallelism:    p.Parallelism,
+		defaultHost:    p.DefaultHost,
+		networkTimeout: p.NetworkTimeout,
+		networkRetries: p.NetworkRetries,
+		lockTimeout:    p.LockTimeout,
+		mirrorRules:    p.Mirrors,
+		goToolchains:   p.GoToolchains,
+
+		reporter: p.Reporter,
+	}
+
+	if e.parallelism < 1 {
+		e.parallelism = 1
+	}
+
+	if e.reporter == nil && p.Quiet && p.Progress != "json" {
+		e.reporter = nopReporter{}
+	} else if e.reporter == nil {
+		e.reporter = NewReporter(p.Progress, e.verbose, p.Spinner)
+	}
+
+	e.userEnv = os.Environ()
+
+	if p.Config != nil {
+		// git and go read the proxy from the environment, so settings from config files are added to it
+		for name, envName := range map[string]string{"http_proxy": "HTTP_PROXY", "https_proxy": "HTTPS_PROXY", "no_proxy": "NO_PROXY"} {
+			if value := p.Config.Get(name); value != "" {
+				e.userEnv = setEnv(e.userEnv, envName+"="+value)
+			}
+		}
+	}
+
+	e.initialPath, _ = lookupEnv(e.userEnv, "PATH")
+	e.initialGoPath, _ = lookupEnv(e.userEnv, "GOPATH")
+
+	e.env = e.userEnv
+	e.gopath = e.initialGoPath
+
+	return e
+}
+
+func (e *engine) setupVendoring() error {
+	if e.dryRun {
+		return nil
+	}
+
+	vendorDirs := []string{path.Join(e.vendorDir, "bin"), path.Join(e.vendorDir, "pkg"), path.Join(e.vendorDir, "src")}
+
+	for _, vendorDir := range vendorDirs {
+		err := os.MkdirAll(vendorDir, 0755)
+
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	return nil
+}
+
+// begin starts an operation on the project: it creates the vendor dirs, takes the vendor lock
+// and opens the run log. Commands run by the returned engine are killed once ctx is cancelled;
+// the returned func ends the operation.
+func (p *Project) begin(ctx context.Context, lock bool) (*engine, func(), error) {
+	e := p.newEngine(ctx)
+
+	err := e.setupVendoring()
+	if err != nil {
+		return nil, nil, errors.Annotate(err, "unable to set up vendor dirs")
+	}
+
+	unlock := func() {}
+
+	// dry runs leave checkouts alone but still fetch into the installed repositories, so they
+	// take the lock too unless there is no vendor dir, and so nothing installed, yet
+	if exists, _ := pathExists(e.vendorDir); lock && (!e.dryRun || exists) {
+		unlock, err = e.acquireVendorLock()
+		if err != nil {
+			return nil, nil, errors.Annotate(err, "unable to lock vendor dir")
+		}
+	}
+
+	if !e.dryRun {
+		e.runLog = e.newCommandLog()
+	}
+
+	return e, func() {
+		e.runLog.close()
+		unlock()
+	}, nil
+}
+
+func (e *engine) readRequiredBunchfile() (*BunchFile, error) {
+	if exists, _ := pathExists(e.bunchfilePath()); !exists {
+		return nil, ErrNoBunchfile
+	}
+
+	return e.readBunchfile()
+}
+
+func (e *engine) readOrCreateBunchfile() (*BunchFile, error) {
+	if exists, _ := pathExists(e.bunchfilePath()); !exists {
+		bunch := createBunchfile()
+		bunch.filename, bunch.defaultHost = e.bunchfilePath(), e.defaultHost
+
+		return bunch, nil
+	}
+
+	return e.readBunchfile()
+}
+
+// Bunchfile reads the project's Bunchfile along with Bunchfile.lock
+func (p *Project) Bunchfile() (*BunchFile, error) {
+	return p.newEngine(context.Background()).readRequiredBunchfile()
+}
+
+// Install installs the Bunchfile's packages, or opts.Packages if given
+func (p *Project) Install(ctx context.Context, opts InstallOptions) (*InstallResult, error) {
+	e, done, err := p.begin(ctx, true)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer done()
+
+	e.buildTargets = opts.Targets
+
+	if len(opts.Packages) == 0 {
+		bunch, err := e.readRequiredBunchfile()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		plan, report, err := e.installPackagesFromBunchfile(bunch, opts.ForceUpdate, opts.CheckUpstream, opts.RespectLocked)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		return &InstallResult{Plan: plan, Report: report}, nil
+	}
+
+	if opts.Global && e.initialGoPath == "" {
+		return nil, errors.New("GOPATH must be set when installing globally")
+	}
+
+	bunch, err := e.readOrCreateBunchfile()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	settingsFrom := bunch // global installs don't use the project's build settings
+	if opts.Global {
+		settingsFrom = nil
+	}
+
+	plan, report, err := e.installPackagesFromRepoStrings(settingsFrom, opts.Packages, opts.Global, opts.ForceUpdate, opts.CheckUpstream, opts.RespectLocked)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	result := &InstallResult{Plan: plan, Report: report}
+
+	if !opts.Save {
+		return result, nil
+	}
+
+	for _, pack := range opts.Packages {
+		if e.dryRun {
+			e.reporter.Message(MessageInfo, fmt.Sprintf("would save %s to Bunchfile", pack))
+			continue
+		}
+
+		err := bunch.AddPackage(pack)
+		if err != nil {
+			return nil, errors.Annotatef(err, "failed adding package %s to save list", pack)
+		}
+	}
+
+	if e.dryRun {
+		return result, nil
+	}
+
+	err = bunch.Save()
+	if err != nil {
+		return nil, errors.Annotate(err, "failed saving Bunchfile")
+	}
+
+	return result, nil
+}
+
+// Uninstall removes packages (and their dependencies no other package uses)
+func (p *Project) Uninstall(ctx context.Context, packages []string, opts UninstallOptions) error {
+	if len(packages) == 0 {
+		return errors.New("no packages given")
+	}
+
+	e, done, err := p.begin(ctx, true)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer done()
+
+	if opts.Global && e.initialGoPath == "" {
+		return errors.New("GOPATH must be set when uninstalling globally")
+	}
+
+	bunch, err := e.readOrCreateBunchfile()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = e.removePackages(packages, bunch, opts.Global)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	if !opts.Save {
+		return nil
+	}
+
+	for _, pack := range packages {
+		if e.dryRun {
+			e.reporter.Message(MessageInfo, fmt.Sprintf("would remove %s from Bunchfile", pack))
+			continue
+		}
+
+		err := bunch.RemovePackage(pack)
+		if err != nil {
+			return errors.Annotatef(err, "failed removing package %s from save list", pack)
+		}
+	}
+
+	if e.dryRun {
+		return nil
+	}
+
+	err = bunch.Save()
+	if err != nil {
+		return errors.Annotate(err, "failed saving Bunchfile")
+	}
+
+	return nil
+}
+
+// Prune removes vendored packages the Bunchfile's packages don't use
+func (p *Project) Prune(ctx context.Context) error {
+	e, done, err := p.begin(ctx, true)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer done()
+
+	bunch, err := e.readRequiredBunchfile()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return e.prunePackages(bunch)
+}
+
+// Upgrade raises the Bunchfile constraints of repos (every package if none are
+// given) to newer releases. UpgradeInteractive asks opts.Choose for each package.
+func (p *Project) Upgrade(ctx context.Context, repos []string, opts UpgradeOptions) error {
+	e, done, err := p.begin(ctx, true)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer done()
+
+	bunch, err := e.readRequiredBunchfile()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return e.upgradePackages(bunch, repos, opts)
+}
+
+// Outdated fetches every package and compares the installed revision with the one its version resolves to
+func (p *Project) Outdated(ctx context.Context) ([]OutdatedPackage, error) {
+	e, done, err := p.begin(ctx, true)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer done()
+
+	e.readOnly = true
+
+	bunch, err := e.readRequiredBunchfile()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return e.checkOutdatedPackages(bunch)
+}
+
+// List returns the Bunchfile's packages along with their installed revisions
+func (p *Project) List(ctx context.Context) ([]ListedPackage, error) {
+	e, done, err := p.begin(ctx, false)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer done()
+
+	bunch, err := e.readRequiredBunchfile()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return e.listPackages(bunch)
+}
+
+// Diff returns the commits and changed files of repo between fromRev (the installed
+// revision if empty) and toRev (the revision the Bunchfile resolves to if empty)
+func (p *Project) Diff(ctx context.Context, repo string, fromRev string, toRev string) (*PackageDiff, error) {
+	e, done, err := p.begin(ctx, true)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer done()
+
+	e.readOnly = true
+
+	pack := parsePackage(repo, e.defaultHost)
+
+	if exists, _ := pathExists(e.bunchfilePath()); exists {
+		bunch, err := e.readBunchfile()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		if index, present := bunch.PackageIndex(pack.Repo); present {
+			pack = bunch.Packages[index]
+		}
+	}
+
+	return e.diffPackage(pack, fromRev, toRev)
+}
+
+// Lock writes Bunchfile.lock with the revisions currently installed
+func (p *Project) Lock(ctx context.Context) error {
+	e, done, err := p.begin(ctx, true)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer done()
+
+	bunch, err := e.readRequiredBunchfile()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return e.lockPackages(bunch)
+}
+
+// Generate writes a Bunchfile listing the imports of the package in the project root
+func (p *Project) Generate(ctx context.Context) error {
+	e, done, err := p.begin(ctx, true)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer done()
+
+	return e.generateBunchfile()
+}
+
+// Logs returns the paths of the logs of recent runs, oldest first. Each holds the commands run
+// by one operation along with their output.
+func (p *Project) Logs() ([]string, error) {
+	return listRunLogs(path.Join(p.VendorDir, ".bunch", "logs"))
+}
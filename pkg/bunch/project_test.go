@@ -0,0 +1,77 @@
+package bunch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bunch-project")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", home)
+
+	subdir := path.Join(dir, "cmd", "tool")
+	_ = os.MkdirAll(subdir, 0755)
+	_ = ioutil.WriteFile(path.Join(dir, "Bunchfile"), []byte("github.com/a/b\n"), 0644)
+	_ = ioutil.WriteFile(path.Join(dir, ProjectConfigName), []byte("parallelism = 3\nvendor_dir = deps\n"), 0644)
+
+	p, err := Open(subdir)
+	assert.Nil(t, err, "project should open")
+	assert.Equal(t, dir, p.Root, "root should be the dir containing the Bunchfile")
+	assert.Equal(t, path.Join(dir, "deps"), p.VendorDir, "vendor dir should come from .bunchrc")
+	assert.Equal(t, 3, p.Parallelism, "parallelism should come from .bunchrc")
+	assert.Equal(t, "github.com", p.DefaultHost, "unset settings should use their defaults")
+	assert.True(t, p.HasBunchfile(), "Bunchfile should be found")
+}
+
+func TestProjectVendorEnv(t *testing.T) {
+	p := &Project{Root: "/src/app", VendorDir: "/src/app/.vendor", Quiet: true}
+	gopath := os.Getenv("GOPATH")
+
+	env, err := p.VendorEnv()
+	assert.Nil(t, err, "vendor env should be built")
+
+	gopaths := []string{}
+	for _, entry := range env {
+		if strings.HasPrefix(entry, "GOPATH=") {
+			gopaths = append(gopaths, entry)
+		}
+		if strings.HasPrefix(entry, "PATH=") {
+			assert.True(t, strings.HasPrefix(entry, "PATH=/src/app/.vendor/bin"), "vendored binaries should come first in PATH")
+		}
+	}
+
+	assert.Equal(t, []string{"GOPATH=/src/app/.vendor"}, gopaths, "GOPATH should be replaced by the vendor dir")
+	assert.Equal(t, gopath, os.Getenv("GOPATH"), "the process environment should be left alone")
+}
+
+func TestProjectList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bunch-project")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	_ = ioutil.WriteFile(path.Join(dir, "Bunchfile"), []byte("github.com/a/b v1.0\n"), 0644)
+
+	p := &Project{Root: dir, VendorDir: path.Join(dir, ".vendor"), Quiet: true}
+	gopath := os.Getenv("GOPATH")
+
+	listed, err := p.List(context.Background())
+	assert.Nil(t, err, "packages should be listed")
+	assert.Equal(t, []ListedPackage{{Repo: "github.com/a/b", Version: "v1.0"}}, listed, "uninstalled package should be listed")
+	assert.Equal(t, gopath, os.Getenv("GOPATH"), "the process environment should be left alone")
+
+	empty := &Project{Root: path.Join(dir, ".vendor"), VendorDir: path.Join(dir, ".vendor"), Quiet: true}
+	_, err = empty.Outdated(context.Background())
+	assert.Equal(t, ErrNoBunchfile, errors.Cause(err), "a missing Bunchfile should be reported")
+}
@@ -0,0 +1,48 @@
+package bunch
+
+type InstalledPackage struct {
+	Repo            string `json:"repo"`
+	Version         string `json:"version,omitempty"`
+	Action          string `json:"action"` // installed, updated, rebuilt, linked or skipped
+	PreviousCommit  string `json:"previous_commit,omitempty"`
+	InstalledCommit string `json:"installed_commit,omitempty"`
+}
+
+type InstallReport struct {
+	Packages []InstalledPackage `json:"packages"`
+}
+
+type OutdatedPackage struct {
+	Repo          string `json:"repo"`
+	Version       string `json:"version,omitempty"`
+	LockedVersion string `json:"locked_version,omitempty"`
+	Locked        bool   `json:"locked"`
+	NeedsUpdate   bool   `json:"needs_update"`
+	Status        string `json:"status"`
+	CommitsBehind int    `json:"commits_behind"`
+	TargetCommit  string `json:"target_commit,omitempty"` // what the installed revision is behind
+
+	PackageRecencyInfo
+}
+
+type ListedPackage struct {
+	Repo            string `json:"repo"`
+	Version         string `json:"version,omitempty"`
+	LockedVersion   string `json:"locked_version,omitempty"`
+	InstalledCommit string `json:"installed_commit,omitempty"`
+	Installed       bool   `json:"installed"`
+	IsSelf          bool   `json:"self"`
+	IsLink          bool   `json:"link"`
+	LinkTarget      string `json:"link_target,omitempty"`
+}
+
+type PackageDiff struct {
+	Repo       string   `json:"repo"`
+	From       string   `json:"from"`
+	FromCommit string   `json:"from_commit"`
+	To         string   `json:"to"`
+	ToCommit   string   `json:"to_commit"`
+	Commits    []string `json:"commits"`     // in To but not From, one line each
+	RolledBack []string `json:"rolled_back"` // in From but not To
+	Diffstat   []string `json:"diffstat"`
+}
@@ -0,0 +1,165 @@
+package bunch
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	version "github.com/hashicorp/go-version"
+	"github.com/juju/errors"
+)
+
+// ResolutionConflict means no revision of Root satisfies all of Requirements, or, if Revision is
+// set, that the vendored revision doesn't and can't be moved. Matches holds, for each requirement,
+// what it would pick on its own.
+type ResolutionConflict struct {
+	Root         string
+	Revision     string
+	Requirements []Requirement
+	Matches      []string
+}
+
+func (c *ResolutionConflict) Error() string {
+	return c.String()
+}
+
+func (c *ResolutionConflict) String() string {
+	header := fmt.Sprintf("no version of %s satisfies every requirement:", c.Root)
+	if c.Revision != "" {
+		header = fmt.Sprintf("the vendored revision %s of %s doesn't satisfy every requirement:", ShortCommit(c.Revision), c.Root)
+	}
+
+	lines := []string{header}
+
+	for i, requirement := range c.Requirements {
+		lines = append(lines, fmt.Sprintf("    - %s required by %s (%s)", orDefault(requirement.Package.Version, "any version"), requirement.Source, c.Matches[i]))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// newResolutionConflict describes what each requirement on git repository root would pick on
+// its own; revision is the vendored revision if it can't be moved, "" otherwise
+func (e *engine) newResolutionConflict(root string, revision string, requirements []Requirement) (*ResolutionConflict, error) {
+	repoDir := path.Join(e.gopath, "src", root)
+
+	conflict := &ResolutionConflict{Root: root, Revision: revision, Requirements: requirements, Matches: make([]string, len(requirements))}
+
+	if exists, _ := pathExists(path.Join(repoDir, ".git")); !exists {
+		for i := range conflict.Matches {
+			conflict.Matches[i] = "not a git repository"
+		}
+
+		return conflict, nil
+	}
+
+	tagList, err := e.gitOutputAt(repoDir, "tag")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	versions, versionToTag := parseVersionTags(strings.Split(tagList, "\n"))
+
+	for i, requirement := range requirements {
+		versionPattern := requirement.Package.Version
+
+		if versionPattern == "" {
+			conflict.Matches[i] = "matches anything"
+		} else if exactRevision, err := e.gitOutputAt(repoDir, "rev-parse", "-q", "--verify", versionPattern+"^{commit}"); err == nil {
+			conflict.Matches[i] = fmt.Sprintf("points at %s", ShortCommit(exactRevision))
+		} else if constraint, err := version.NewConstraint(versionPattern); err != nil {
+			conflict.Matches[i] = "not a version"
+		} else if tag := matchingTag(versions, versionToTag, constraint); tag != "" {
+			conflict.Matches[i] = fmt.Sprintf("picks %s", tag)
+		} else {
+			conflict.Matches[i] = "matches no tag"
+		}
+	}
+
+	return conflict, nil
+}
+
+func (e *engine) gitOutputAt(repoDir string, args ...string) (string, error) {
+	return e.outputAt(repoDir, "git", args...)
+}
+
+func (e *engine) outputAt(repoDir string, name string, args ...string) (string, error) { // trimmed output of a command run in repoDir
+	output, err := e.commandOutput(e.command(repoDir, name, args...))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// resolveRequirements picks the revision of git repository root that satisfies every requirement:
+// the commit all exact versions (tags, branches, commits) point at, or otherwise the tag
+// matchingTag picks for every version constraint. Requirements without a version match anything.
+func (e *engine) resolveRequirements(root string, requirements []Requirement) (string, error) {
+	repoDir := path.Join(e.gopath, "src", root)
+
+	var exactRevision string
+	exactConflict := false
+	allConstraints := []version.Constraints{}
+
+	for _, requirement := range requirements {
+		versionPattern := requirement.Package.Version
+		if versionPattern == "" {
+			continue
+		}
+
+		if revision, err := e.gitOutputAt(repoDir, "rev-parse", "-q", "--verify", versionPattern+"^{commit}"); err == nil {
+			if exactRevision != "" && exactRevision != revision {
+				exactConflict = true
+			}
+			exactRevision = revision
+			continue
+		}
+
+		constraint, err := version.NewConstraint(versionPattern)
+		if err != nil {
+			return "", errors.Annotatef(err, "invalid version %s for %s required by %s", versionPattern, root, requirement.Source)
+		}
+
+		allConstraints = append(allConstraints, constraint)
+	}
+
+	tagList, err := e.gitOutputAt(repoDir, "tag")
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	versions, versionToTag := parseVersionTags(strings.Split(tagList, "\n"))
+
+	if exactRevision != "" && !exactConflict {
+		headTags, err := e.gitOutputAt(repoDir, "tag", "--points-at", exactRevision)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+
+		exactVersions, exactVersionToTag := parseVersionTags(strings.Split(headTags, "\n"))
+		if len(allConstraints) == 0 || matchingTag(exactVersions, exactVersionToTag, allConstraints...) != "" {
+			return exactRevision, nil
+		}
+	} else if exactRevision == "" {
+		if len(allConstraints) == 0 {
+			return "", nil
+		}
+
+		if tag := matchingTag(versions, versionToTag, allConstraints...); tag != "" {
+			revision, err := e.gitOutputAt(repoDir, "rev-parse", "-q", "--verify", tag+"^{commit}")
+			if err != nil {
+				return "", errors.Trace(err)
+			}
+
+			return revision, nil
+		}
+	}
+
+	conflict, err := e.newResolutionConflict(root, "", requirements)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	return "", conflict
+}
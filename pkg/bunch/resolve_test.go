@@ -0,0 +1,60 @@
+package bunch
+
+import (
+	"testing"
+
+	version "github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchingTagAll(t *testing.T) {
+	versions, versionToTag := parseVersionTags([]string{"v1.0.0", "v1.0.3", "v1.1.0", "v1.2.0", "v2.0.0"})
+
+	minor := mustConstraint("~> 1.0")
+	atLeast := mustConstraint(">= 1.0.2")
+	above := mustConstraint("> 1.0.3")
+	patch := mustConstraint("~> 1.0.0")
+
+	assert.Equal(t, "v1.1.0", matchingTag(versions, versionToTag, minor, atLeast, above), "lowest tag matching every constraint should win")
+	assert.Equal(t, "v1.0.3", matchingTag(versions, versionToTag, patch, atLeast), "every constraint should limit the pick")
+	assert.Equal(t, "", matchingTag(versions, versionToTag, patch, mustConstraint(">= 1.1")), "clashing constraints should match nothing")
+}
+
+func mustConstraint(constraint string) version.Constraints {
+	c, err := version.NewConstraint(constraint)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func TestResolutionConflictString(t *testing.T) {
+	conflict := &ResolutionConflict{
+		Root: "github.com/acme/dep",
+		Requirements: []Requirement{
+			{Package: Package{Version: "~> 1.0.0"}, Source: "Bunchfile"},
+			{Package: Package{Version: "~> 2.0"}, Source: "github.com/acme/lib"},
+		},
+		Matches: []string{"picks v1.0.0", "matches no tag"},
+	}
+
+	assert.Equal(t, `no version of github.com/acme/dep satisfies every requirement:
+    - ~> 1.0.0 required by Bunchfile (picks v1.0.0)
+    - ~> 2.0 required by github.com/acme/lib (matches no tag)`, conflict.Error(), "conflict should explain every requirement")
+}
+
+func TestResolutionConflictStringVendored(t *testing.T) {
+	conflict := &ResolutionConflict{
+		Root:     "github.com/acme/dep",
+		Revision: "0123456789abcdef",
+		Requirements: []Requirement{
+			{Package: Package{Version: "~> 1.0.0"}, Source: "github.com/acme/a"},
+			{Package: Package{Version: "~> 1.1"}, Source: "github.com/acme/b"},
+		},
+		Matches: []string{"picks v1.0.0", "picks v1.1.0"},
+	}
+
+	assert.Equal(t, `the vendored revision 0123456 of github.com/acme/dep doesn't satisfy every requirement:
+    - ~> 1.0.0 required by github.com/acme/a (picks v1.0.0)
+    - ~> 1.1 required by github.com/acme/b (picks v1.1.0)`, conflict.Error(), "conflict should name the vendored revision")
+}
@@ -0,0 +1,180 @@
+package bunch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// buildFingerprint is what an installed package was built from. A package whose fingerprint
+// differs from the current one (another revision checked out, another Go version or target,
+// other flags, GOFLAGS or environment) is rebuilt.
+type buildFingerprint struct {
+	Revision  string   `json:"revision"`
+	GoVersion string   `json:"go_version"`
+	GOOS      string   `json:"goos"`
+	GOARCH    string   `json:"goarch"`
+	Flags     []string `json:"flags,omitempty"`   // passed to go build and go install, e.g. -tags netgo
+	GOFLAGS   string   `json:"goflags,omitempty"` // as set for the build; Flags override what it says
+	Env       []string `json:"env,omitempty"`
+}
+
+// buildState maps installed packages to the fingerprints they were built with, one per target.
+// It is kept next to the packages it describes, in .vendor/.bunch/state.json (or
+// $GOPATH/.bunch/state.json for global installs).
+type buildState struct {
+	Packages map[string][]buildFingerprint `json:"packages"`
+}
+
+func (e *engine) buildStatePath() string {
+	return path.Join(e.gopath, ".bunch", "state.json")
+}
+
+func (e *engine) readBuildState() (*buildState, error) {
+	state := &buildState{Packages: map[string][]buildFingerprint{}}
+
+	data, err := ioutil.ReadFile(e.buildStatePath())
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	err = json.Unmarshal(data, state)
+	if err != nil || state.Packages == nil {
+		// a corrupt state file only costs rebuilds
+		return &buildState{Packages: map[string][]buildFingerprint{}}, nil
+	}
+
+	return state, nil
+}
+
+func (e *engine) saveBuildState(state *buildState) error {
+	data, err := json.MarshalIndent(state, "", "    ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = os.MkdirAll(path.Dir(e.buildStatePath()), 0755)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return writeFileAtomic(e.buildStatePath(), append(data, '\n'), 0644)
+}
+
+// currentFingerprint is the fingerprint a build of revision for target with settings would have
+// now; the zero Target stands for the host
+func (e *engine) currentFingerprint(revision string, target Target, settings BuildSettings) (buildFingerprint, error) {
+	if e.toolchainFingerprint == nil {
+		versionOutput, err := e.commandOutput(e.command("", "go", "version"))
+		if err != nil {
+			return buildFingerprint{}, errors.Annotate(err, "unable to determine go version")
+		}
+
+		envOutput, err := e.commandOutput(e.command("", "go", "env", "GOOS", "GOARCH"))
+		if err != nil {
+			return buildFingerprint{}, errors.Annotate(err, "unable to determine go target")
+		}
+
+		fields := strings.Fields(string(versionOutput)) // go version go1.21.5 linux/amd64
+		target := strings.Fields(string(envOutput))
+		if len(fields) < 3 || len(target) != 2 {
+			return buildFingerprint{}, errors.Errorf("unexpected output from go: %s %s", versionOutput, envOutput)
+		}
+
+		e.toolchainFingerprint = &buildFingerprint{GoVersion: fields[2], GOOS: target[0], GOARCH: target[1]}
+	}
+
+	fingerprint := *e.toolchainFingerprint
+	fingerprint.Revision = revision
+	fingerprint.GOFLAGS, _ = lookupEnv(e.env, "GOFLAGS")
+	fingerprint.Env = settings.Env
+
+	if args := settings.args(); len(args) > 0 { // nil otherwise, as it reads back from the state file
+		fingerprint.Flags = args
+	}
+
+	if target != (Target{}) {
+		fingerprint.GOOS, fingerprint.GOARCH = target.OS, target.Arch
+	}
+
+	return fingerprint, nil
+}
+
+func (e *engine) hostTarget() (Target, error) {
+	fingerprint, err := e.currentFingerprint("", Target{}, BuildSettings{})
+	if err != nil {
+		return Target{}, errors.Trace(err)
+	}
+
+	return Target{OS: fingerprint.GOOS, Arch: fingerprint.GOARCH}, nil
+}
+
+func (e *engine) builtFromCurrent(repo string, revision string, target Target, settings BuildSettings) (bool, error) { // whether repo's recorded fingerprint for target is still current
+	state, err := e.readBuildState()
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+
+	current, err := e.currentFingerprint(revision, target, settings)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+
+	for _, recorded := range state.Packages[getRealRepoPath(repo)] {
+		if recorded.GOOS == current.GOOS && recorded.GOARCH == current.GOARCH {
+			return reflect.DeepEqual(recorded, current), nil
+		}
+	}
+
+	return false, nil
+}
+
+func (e *engine) recordBuild(repo string, target Target, settings BuildSettings) error {
+	revision, err := e.getInstalledRevision(repo)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	fingerprint, err := e.currentFingerprint(revision, target, settings)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	state, err := e.readBuildState()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	recorded := []buildFingerprint{fingerprint}
+	for _, other := range state.Packages[getRealRepoPath(repo)] {
+		if other.GOOS != fingerprint.GOOS || other.GOARCH != fingerprint.GOARCH {
+			recorded = append(recorded, other)
+		}
+	}
+
+	state.Packages[getRealRepoPath(repo)] = recorded
+
+	return e.saveBuildState(state)
+}
+
+func (e *engine) forgetBuild(repo string) error {
+	state, err := e.readBuildState()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	if _, ok := state.Packages[getRealRepoPath(repo)]; !ok {
+		return nil
+	}
+
+	delete(state.Packages, getRealRepoPath(repo))
+
+	return e.saveBuildState(state)
+}
@@ -0,0 +1,73 @@
+package bunch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuiltFromCurrent(t *testing.T) {
+	e, cleanup := withTempGopath(t)
+	defer cleanup()
+
+	e.env = setEnv(e.env, "GOFLAGS=")
+	e.toolchainFingerprint = &buildFingerprint{GoVersion: "go1.21.5", GOOS: "linux", GOARCH: "amd64"}
+
+	built, err := e.builtFromCurrent("github.com/a/b", "abc123", Target{}, BuildSettings{})
+	assert.Nil(t, err, "missing state file should be fine")
+	assert.False(t, built, "package without a fingerprint should be built")
+
+	state, _ := e.readBuildState()
+	fingerprint, _ := e.currentFingerprint("abc123", Target{}, BuildSettings{})
+	state.Packages["github.com/a/b"] = []buildFingerprint{fingerprint}
+	assert.Nil(t, e.saveBuildState(state), "state should be saved")
+
+	built, _ = e.builtFromCurrent("github.com/a/b", "abc123", Target{}, BuildSettings{})
+	assert.True(t, built, "unchanged fingerprint should not need a build")
+
+	built, _ = e.builtFromCurrent("github.com/a/b", "abc123", Target{}, BuildSettings{Env: []string{"CGO_ENABLED=0"}})
+	assert.False(t, built, "other build settings should need a build")
+
+	built, _ = e.builtFromCurrent("github.com/a/b", "def456", Target{}, BuildSettings{})
+	assert.False(t, built, "another revision should need a build")
+
+	e.env = setEnv(e.env, "GOFLAGS=-mod=mod -tags=netgo,osusergo")
+	built, _ = e.builtFromCurrent("github.com/a/b", "abc123", Target{}, BuildSettings{})
+	assert.False(t, built, "other GOFLAGS should need a build")
+	e.env = setEnv(e.env, "GOFLAGS=")
+
+	e.toolchainFingerprint = &buildFingerprint{GoVersion: "go1.22.0", GOOS: "linux", GOARCH: "amd64"}
+	built, _ = e.builtFromCurrent("github.com/a/b", "abc123", Target{}, BuildSettings{})
+	assert.False(t, built, "another go version should need a build")
+
+	arm := Target{OS: "linux", Arch: "arm64"}
+	built, _ = e.builtFromCurrent("github.com/a/b", "abc123", arm, BuildSettings{})
+	assert.False(t, built, "target that wasn't built for should need a build")
+
+	armFingerprint, _ := e.currentFingerprint("abc123", arm, BuildSettings{})
+	state, _ = e.readBuildState()
+	state.Packages["github.com/a/b"] = append(state.Packages["github.com/a/b"], armFingerprint)
+	assert.Nil(t, e.saveBuildState(state), "state should be saved")
+
+	built, _ = e.builtFromCurrent("github.com/a/b", "abc123", arm, BuildSettings{})
+	assert.True(t, built, "each target should have its own fingerprint")
+
+	assert.Nil(t, e.forgetBuild("github.com/a/b"), "fingerprint should be removed")
+	state, _ = e.readBuildState()
+	assert.Equal(t, 0, len(state.Packages), "removed package should be forgotten")
+}
+
+func TestCurrentFingerprintFlags(t *testing.T) {
+	e := testEngine()
+	e.toolchainFingerprint = &buildFingerprint{GoVersion: "go1.21.5", GOOS: "linux", GOARCH: "amd64"}
+
+	e.env = setEnv(e.env, "GOFLAGS=-tags=sqlite -trimpath")
+	fingerprint, err := e.currentFingerprint("abc123", Target{}, BuildSettings{Tags: []string{"netgo"}, LDFlags: []string{"-s -w"}})
+	assert.Nil(t, err, "fingerprint should be taken")
+	assert.Equal(t, []string{"-tags", "netgo", "-ldflags", "-s -w"}, fingerprint.Flags, "flags should be recorded as passed to go, since -tags overrides GOFLAGS")
+	assert.Equal(t, "-tags=sqlite -trimpath", fingerprint.GOFLAGS, "GOFLAGS should be recorded as set")
+
+	e.env = setEnv(e.env, "GOFLAGS=")
+	fingerprint, _ = e.currentFingerprint("abc123", Target{}, BuildSettings{})
+	assert.Nil(t, fingerprint.Flags, "no settings should record no flags")
+}
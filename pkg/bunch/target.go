@@ -0,0 +1,91 @@
+package bunch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// Target is a platform to build packages for, written GOOS/GOARCH (e.g. linux/arm64).
+// The zero Target stands for the host, or whatever GOOS and GOARCH are set to.
+type Target struct {
+	OS   string
+	Arch string
+}
+
+func (t Target) String() string {
+	return fmt.Sprintf("%s/%s", t.OS, t.Arch)
+}
+
+func (t Target) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// ParseTargets parses a comma-separated list of GOOS/GOARCH pairs, e.g. linux/arm64,darwin/amd64
+func ParseTargets(list string) ([]Target, error) {
+	targets := []Target{}
+
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, newError(KindUsage, "invalid target %q, expected GOOS/GOARCH (e.g. linux/arm64)", entry)
+		}
+
+		target := Target{OS: parts[0], Arch: parts[1]}
+
+		duplicate := false
+		for _, seen := range targets {
+			duplicate = duplicate || seen == target
+		}
+
+		if !duplicate {
+			targets = append(targets, target)
+		}
+	}
+
+	return targets, nil
+}
+
+func joinTargets(targets []Target) string {
+	names := []string{}
+	for _, target := range targets {
+		names = append(names, target.String())
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func (t Target) env() []string { // added to the environment of go commands building for t
+	if t == (Target{}) {
+		return nil
+	}
+
+	return []string{"GOOS=" + t.OS, "GOARCH=" + t.Arch}
+}
+
+// crossTargets is buildTargets without the host, which every install builds for anyway
+func (e *engine) crossTargets() ([]Target, error) {
+	if len(e.buildTargets) == 0 {
+		return nil, nil
+	}
+
+	host, err := e.hostTarget()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	targets := []Target{}
+	for _, target := range e.buildTargets {
+		if target != host {
+			targets = append(targets, target)
+		}
+	}
+
+	return targets, nil
+}
@@ -0,0 +1,19 @@
+package bunch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTargets(t *testing.T) {
+	targets, err := ParseTargets("linux/arm64, darwin/amd64,linux/arm64")
+	assert.Nil(t, err, "targets should parse")
+	assert.Equal(t, []Target{{OS: "linux", Arch: "arm64"}, {OS: "darwin", Arch: "amd64"}}, targets, "duplicates should be dropped")
+
+	for _, invalid := range []string{"linux", "linux/", "/amd64", "linux/arm/v7"} {
+		_, err := ParseTargets(invalid)
+		assert.NotNil(t, err, "target %q should be rejected", invalid)
+		assert.Equal(t, KindUsage, KindOf(err), "invalid targets should be usage errors")
+	}
+}
@@ -0,0 +1,146 @@
+package bunch
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/juju/errors"
+)
+
+var goVersionRegexp = regexp.MustCompile(`^\d+\.\d+(\.\d+)?((rc|beta)\d+)?$`)
+
+type resolvedToolchain struct {
+	required string // the Bunchfile's !go version, "" if it has none
+	bin      string // bin directory of an installed Go version satisfying it, put first on PATH
+	checked  bool   // go version has been checked against required
+}
+
+func validateGoVersion(required string) error {
+	if !goVersionRegexp.MatchString(required) {
+		return errors.Errorf("invalid go version %q, expected e.g. 1.21.5 or 1.21", required)
+	}
+
+	return nil
+}
+
+// goVersionMatches reports whether actual (as printed by go version, e.g. go1.21.5) satisfies the
+// !go directive's required version; 1.21 is satisfied by any 1.21.x
+func goVersionMatches(required string, actual string) bool {
+	actual = strings.TrimPrefix(actual, "go")
+
+	return actual == required || strings.HasPrefix(actual, required+".")
+}
+
+// findToolchain looks in dir for the newest installed Go version satisfying required, and returns
+// its bin directory, or "" if there is none
+func findToolchain(dir string, required string) (string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	var best *version.Version
+	bestBin := ""
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !goVersionMatches(required, entry.Name()) {
+			continue
+		}
+
+		bin := path.Join(dir, entry.Name(), "bin")
+		if _, err := lookPathIn("go", bin, executableExtensions()); err != nil {
+			continue
+		}
+
+		candidate, err := version.NewVersion(strings.TrimPrefix(entry.Name(), "go"))
+		if err != nil {
+			continue
+		}
+
+		if best == nil || candidate.GreaterThan(best) {
+			best, bestBin = candidate, bin
+		}
+	}
+
+	return bestBin, nil
+}
+
+func expandHome(dir string) string {
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return path.Join(home, dir[1:])
+		}
+	}
+
+	return dir
+}
+
+// resolveGoToolchain reads the Bunchfile's !go version and looks for it in goToolchains, once per
+// operation
+func (e *engine) resolveGoToolchain() error {
+	if e.goToolchain != nil {
+		return nil
+	}
+
+	resolved := &resolvedToolchain{}
+
+	if exists, _ := pathExists(e.bunchfilePath()); exists {
+		b, err := e.readBunchfile()
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		resolved.required = b.GoVersion
+	}
+
+	if resolved.required != "" && e.goToolchains != "" {
+		bin, err := findToolchain(expandHome(e.goToolchains), resolved.required)
+		if err != nil {
+			return errors.Annotatef(err, "failed looking for go %s in %s", resolved.required, e.goToolchains)
+		}
+
+		resolved.bin = bin
+	}
+
+	e.goToolchain = resolved
+
+	return nil
+}
+
+// checkGoVersion fails unless the go on PATH is the version the Bunchfile asks for
+func (e *engine) checkGoVersion() error {
+	if e.goToolchain == nil || e.goToolchain.required == "" || e.goToolchain.checked {
+		return nil
+	}
+
+	required := e.goToolchain.required
+
+	output, err := e.command("", "go", "version").Output() // not logged, bunch go runs this too
+	if err != nil {
+		return newError(KindBunchfile, "Bunchfile requires go %s, but go version failed: %s", required, err)
+	}
+
+	actual := strings.TrimSpace(string(output))
+	if fields := strings.Fields(actual); len(fields) >= 3 { // go version go1.21.5 linux/amd64
+		actual = fields[2]
+	}
+
+	if !goVersionMatches(required, actual) {
+		hint := "set go_toolchains to a directory of installed Go versions"
+		if e.goToolchains != "" {
+			hint = "install it into " + path.Join(expandHome(e.goToolchains), "go"+required)
+		}
+
+		return newError(KindBunchfile, "Bunchfile requires go %s, but go on PATH is %s (%s)", required, actual, hint)
+	}
+
+	e.goToolchain.checked = true
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package bunch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoVersionMatches(t *testing.T) {
+	assert.True(t, goVersionMatches("1.21.5", "go1.21.5"), "exact version should match")
+	assert.True(t, goVersionMatches("1.21", "go1.21.5"), "minor version should match any patch")
+	assert.False(t, goVersionMatches("1.21", "go1.210.1"), "minor version should match whole components")
+	assert.False(t, goVersionMatches("1.21.5", "go1.21.6"), "other patch should not match")
+
+	assert.Nil(t, validateGoVersion("1.22rc1"), "release candidates should be valid")
+	assert.NotNil(t, validateGoVersion("go1.21"), "versions should be given without the go prefix")
+}
+
+func installFakeGo(t *testing.T, dir string, version string) {
+	bin := path.Join(dir, "go"+version, "bin")
+	assert.Nil(t, os.MkdirAll(bin, 0755), "toolchain dir should be created")
+
+	script := fmt.Sprintf("#!/bin/sh\necho go version go%s linux/amd64\n", version)
+	assert.Nil(t, ioutil.WriteFile(path.Join(bin, "go"), []byte(script), 0755), "fake go should be written")
+}
+
+func TestFindToolchain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bunch-toolchains")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	installFakeGo(t, dir, "1.21.4")
+	installFakeGo(t, dir, "1.21.10")
+	installFakeGo(t, dir, "1.22.0")
+	assert.Nil(t, os.MkdirAll(path.Join(dir, "go1.21.12"), 0755), "incomplete toolchain should be created")
+
+	bin, err := findToolchain(dir, "1.21")
+	assert.Nil(t, err, "toolchains should be listed")
+	assert.Equal(t, path.Join(dir, "go1.21.10", "bin"), bin, "newest installed matching version should be picked")
+
+	bin, _ = findToolchain(dir, "1.21.4")
+	assert.Equal(t, path.Join(dir, "go1.21.4", "bin"), bin, "exact version should be picked")
+
+	bin, _ = findToolchain(dir, "1.20")
+	assert.Equal(t, "", bin, "missing version should not be found")
+
+	bin, err = findToolchain(path.Join(dir, "missing"), "1.21")
+	assert.Nil(t, err, "missing toolchains dir should be fine")
+	assert.Equal(t, "", bin, "nothing should be found in a missing dir")
+}
+
+func TestSetVendorEnvUsesToolchain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bunch-toolchains")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	installFakeGo(t, path.Join(dir, "sdk"), "1.21.5")
+	_ = ioutil.WriteFile(path.Join(dir, "Bunchfile"), []byte("!go 1.21\ngithub.com/a/b\n"), 0644)
+
+	p := &Project{Root: dir, VendorDir: path.Join(dir, ".vendor"), Quiet: true, GoToolchains: path.Join(dir, "sdk")}
+
+	env, err := p.VendorEnv()
+	assert.Nil(t, err, "installed toolchain should satisfy !go")
+	assert.Contains(t, env, fmt.Sprintf("PATH=%s%c%s%c%s", path.Join(dir, "sdk", "go1.21.5", "bin"), os.PathListSeparator, path.Join(dir, ".vendor", "bin"), os.PathListSeparator, os.Getenv("PATH")), "toolchain should be first on PATH")
+
+	_ = ioutil.WriteFile(path.Join(dir, "Bunchfile"), []byte("!go 1.19.2\ngithub.com/a/b\n"), 0644)
+
+	_, err = p.VendorEnv()
+	assert.Equal(t, KindBunchfile, KindOf(err), "missing go version should fail")
+	assert.Contains(t, fmt.Sprint(err), "Bunchfile requires go 1.19.2", "error should name the required version")
+	assert.Contains(t, fmt.Sprint(err), path.Join(dir, "sdk", "go1.19.2"), "error should say where to install it")
+}
+
+func TestFindToolchainWithExtensions(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("PATHEXT is only used on Windows")
+	}
+
+	dir, err := ioutil.TempDir("", "bunch-toolchains")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	bin := path.Join(dir, "go1.21.5", "bin")
+	_ = os.MkdirAll(bin, 0755)
+	_ = ioutil.WriteFile(path.Join(bin, "go.exe"), []byte("MZ"), 0644)
+
+	found, err := findToolchain(dir, "1.21")
+	assert.Nil(t, err, "toolchains should be listed")
+	assert.Equal(t, bin, found, "toolchains with go.exe should be found")
+}
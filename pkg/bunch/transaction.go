@@ -0,0 +1,345 @@
+package bunch
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+type VendorSnapshot struct {
+	SrcDir    string
+	Revisions map[string]string // repo root dir -> revision
+	Branches  map[string]string // repo root dir -> branch checked out, for git repos not on a detached HEAD
+	Created   []string          // links and clones created during the install
+	WalkedAll bool
+
+	BackupDir  string
+	Artifacts  map[string]string // archive or binary -> where it was moved before a rebuild
+	Built      []string          // packages rebuilt during the install
+	BuildState []byte            // state.json as it was, nil if there was none
+
+	engine *engine // the operation that took the snapshot
+	mutex  sync.Mutex
+}
+
+func findRepoRoots(srcDir string) ([]string, error) {
+	repoRoots := []string{}
+
+	if exists, _ := pathExists(srcDir); !exists {
+		return repoRoots, nil
+	}
+
+	err := filepath.Walk(srcDir, func(walkPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		name := info.Name()
+		if name == ".git" || name == ".hg" || name == ".bzr" || name == ".svn" {
+			return filepath.SkipDir
+		}
+
+		for _, vcsDir := range []string{".git", ".hg", ".bzr"} {
+			if exists, _ := pathExists(path.Join(walkPath, vcsDir)); exists {
+				repoRoots = append(repoRoots, walkPath)
+				break
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return repoRoots, nil
+}
+
+// snapshotVendor records the revision of every repository under $GOPATH/src, or only those
+// of the given packages when repos is non-nil (used for global installs, where walking the
+// whole GOPATH would be too slow)
+func (e *engine) snapshotVendor(repos []string) (*VendorSnapshot, error) {
+	gopath := e.gopath
+
+	snapshot := &VendorSnapshot{
+		SrcDir:    path.Join(gopath, "src"),
+		Revisions: make(map[string]string),
+		Branches:  make(map[string]string),
+		WalkedAll: repos == nil,
+		BackupDir: path.Join(gopath, ".bunch", "rollback"),
+		Artifacts: make(map[string]string),
+		engine:    e,
+	}
+
+	// left behind by an install that was killed before it could roll back or clean up
+	err := os.RemoveAll(snapshot.BackupDir)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	snapshot.BuildState, err = ioutil.ReadFile(e.buildStatePath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Trace(err)
+	}
+
+	var repoRoots []string
+
+	if repos == nil {
+		repoRoots, err = findRepoRoots(snapshot.SrcDir)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	} else {
+		for _, repo := range repos {
+			repoRoot, err := e.getPackageRootDir(getRealRepoPath(repo))
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+
+			repoRoots = append(repoRoots, repoRoot)
+		}
+	}
+
+	for _, repoRoot := range repoRoots {
+		if exists, _ := pathExists(repoRoot); !exists {
+			continue
+		}
+
+		revision, err := e.getRevisionAt(repoRoot)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		if revision != "" {
+			snapshot.Revisions[repoRoot] = revision
+		}
+
+		if branch := e.getBranchAt(repoRoot); branch != "" {
+			snapshot.Branches[repoRoot] = branch
+		}
+	}
+
+	return snapshot, nil
+}
+
+func (s *VendorSnapshot) RecordCreated(createdPath string) { // safe to call from parallel fetches
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.Created = append(s.Created, createdPath)
+}
+
+// SaveArtifacts moves the archives and binaries of pack out of the way before it is rebuilt, so
+// Restore can put them back
+func (s *VendorSnapshot) SaveArtifacts(pack string) error {
+	for _, built := range s.Built {
+		if built == pack {
+			return nil
+		}
+	}
+
+	s.Built = append(s.Built, pack)
+
+	archives, binaries := s.engine.packageArtifacts(pack)
+
+	for _, artifact := range append(archives, binaries...) {
+		if exists, _ := pathExists(artifact); !exists {
+			continue
+		}
+
+		backup := path.Join(s.BackupDir, fmt.Sprint(len(s.Artifacts)))
+
+		err := os.MkdirAll(s.BackupDir, 0755)
+		if err == nil {
+			err = os.Rename(artifact, backup)
+		}
+
+		if err != nil {
+			return errors.Annotatef(err, "failed saving %s before rebuilding %s", artifact, pack)
+		}
+
+		s.Artifacts[artifact] = backup
+	}
+
+	return nil
+}
+
+// Discard removes the artifacts saved for a rollback once the install succeeded
+func (s *VendorSnapshot) Discard() error {
+	return errors.Trace(os.RemoveAll(s.BackupDir))
+}
+
+func (s *VendorSnapshot) restoreArtifacts() []string { // returns failures
+	e := s.engine
+	failures := []string{}
+
+	for _, pack := range s.Built {
+		archives, binaries := e.packageArtifacts(pack)
+
+		for _, artifact := range append(archives, binaries...) {
+			err := os.RemoveAll(artifact)
+			if err != nil {
+				failures = append(failures, err.Error())
+			}
+		}
+	}
+
+	for artifact, backup := range s.Artifacts {
+		err := os.MkdirAll(path.Dir(artifact), 0755)
+		if err == nil {
+			err = os.Rename(backup, artifact)
+		}
+
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	var err error
+	if s.BuildState != nil {
+		err = writeFileAtomic(e.buildStatePath(), s.BuildState, 0644)
+	} else {
+		err = os.RemoveAll(e.buildStatePath())
+	}
+
+	if err != nil {
+		failures = append(failures, err.Error())
+	}
+
+	err = s.Discard()
+	if err != nil {
+		failures = append(failures, err.Error())
+	}
+
+	return failures
+}
+
+func (e *engine) getBranchAt(repoPath string) string { // the branch a git checkout is on, "" if detached or not git
+	if exists, _ := pathExists(path.Join(repoPath, ".git")); !exists {
+		return ""
+	}
+
+	output, err := e.commandOutput(e.command(repoPath, "git", "symbolic-ref", "-q", "--short", "HEAD"))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(output))
+}
+
+// setRevisionAt checks out revision in repoPath, on branch if given (moving the branch there)
+func (e *engine) setRevisionAt(repoPath string, revision string, branch string) error {
+	var checkoutCommand []string
+
+	if exists, _ := pathExists(path.Join(repoPath, ".git")); exists {
+		// left behind if a checkout was killed; the vendor lock rules out another git using it
+		_ = os.Remove(path.Join(repoPath, ".git", "index.lock"))
+
+		checkoutCommand = []string{"git", "checkout", "-q", revision}
+		if branch != "" {
+			checkoutCommand = []string{"git", "checkout", "-q", "-B", branch, revision}
+		}
+	} else if exists, _ := pathExists(path.Join(repoPath, ".hg")); exists {
+		checkoutCommand = []string{"hg", "update", "-C", revision}
+	} else if exists, _ := pathExists(path.Join(repoPath, ".bzr")); exists {
+		checkoutCommand = []string{"bzr", "update", "-r", revision}
+	} else {
+		return nil
+	}
+
+	_, err := e.runCommand(e.command(repoPath, checkoutCommand[0], checkoutCommand[1:]...))
+	if err != nil {
+		return errors.Annotatef(err, "failed restoring %s to %s", repoPath, revision)
+	}
+
+	return nil
+}
+
+// Restore puts every snapshotted repository back at its recorded revision (and branch), puts back
+// the archives and binaries of rebuilt packages, and removes repositories and links that didn't
+// exist when the snapshot was taken
+func (s *VendorSnapshot) Restore() error {
+	failures := []string{}
+
+	// restoring has to finish even if the operation was interrupted
+	e := *s.engine
+	e.ctx = context.Background()
+
+	for _, createdPath := range s.Created {
+		err := os.RemoveAll(createdPath)
+		if err == nil {
+			err = cleanEmpties(createdPath)
+		}
+
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	for repoRoot, revision := range s.Revisions {
+		currentRevision, err := e.getRevisionAt(repoRoot)
+		if err == nil && currentRevision == revision && e.getBranchAt(repoRoot) == s.Branches[repoRoot] {
+			continue
+		}
+
+		step := Step{Action: "restoring", Subject: strings.TrimPrefix(repoRoot, s.SrcDir+"/"), Detail: "to " + ShortCommit(revision), Verbose: true}
+		e.reporter.Begin(step)
+
+		err = e.setRevisionAt(repoRoot, revision, s.Branches[repoRoot])
+		e.reportStep(step, err)
+
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	if s.WalkedAll {
+		repoRoots, err := findRepoRoots(s.SrcDir)
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+
+		for _, repoRoot := range repoRoots {
+			if _, existed := s.Revisions[repoRoot]; existed {
+				continue
+			}
+
+			step := Step{Action: "removing newly fetched", Subject: strings.TrimPrefix(repoRoot, s.SrcDir+"/"), Verbose: true}
+			e.reporter.Begin(step)
+
+			err := os.RemoveAll(repoRoot)
+			if err == nil {
+				err = cleanEmpties(repoRoot)
+			}
+
+			e.reportStep(step, err)
+
+			if err != nil {
+				failures = append(failures, err.Error())
+			}
+		}
+	}
+
+	failures = append(failures, s.restoreArtifacts()...)
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%s", strings.Join(failures, "; "))
+	}
+
+	return nil
+}
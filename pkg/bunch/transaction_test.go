@@ -0,0 +1,92 @@
+package bunch
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readString(filename string) string {
+	contents, _ := ioutil.ReadFile(filename)
+	return string(contents)
+}
+
+func TestRestoreRollsBackSourcesAndArtifacts(t *testing.T) {
+	e, cleanup := withTempGopath(t)
+	defer cleanup()
+
+	gopath := e.gopath
+	repoDir := path.Join(gopath, "src", "github.com/acme/tool")
+	archive := path.Join(gopath, "pkg", "linux_amd64", "github.com/acme/tool.a")
+	binary := path.Join(gopath, "bin", "tool")
+
+	_ = os.MkdirAll(repoDir, 0755)
+	gitIn(t, repoDir, "init", "-q", "-b", "main")
+	gitIn(t, repoDir, "commit", "-q", "--allow-empty", "-m", "first")
+	first := gitIn(t, repoDir, "rev-parse", "HEAD")
+
+	_ = os.MkdirAll(path.Dir(archive), 0755)
+	_ = os.MkdirAll(path.Dir(binary), 0755)
+	_ = ioutil.WriteFile(archive, []byte("old archive"), 0644)
+	_ = ioutil.WriteFile(binary, []byte("old binary"), 0755)
+	_ = os.MkdirAll(path.Dir(e.buildStatePath()), 0755)
+	_ = ioutil.WriteFile(e.buildStatePath(), []byte("old state"), 0644)
+
+	snapshot, err := e.snapshotVendor(nil)
+	assert.Nil(t, err, "snapshot should be taken")
+
+	// what a failed install leaves behind
+	gitIn(t, repoDir, "commit", "-q", "--allow-empty", "-m", "second")
+	gitIn(t, repoDir, "checkout", "-q", "--detach", "HEAD")
+
+	assert.Nil(t, snapshot.SaveArtifacts("github.com/acme/tool"), "artifacts should be saved")
+	_ = ioutil.WriteFile(archive, []byte("new archive"), 0644)
+	_ = ioutil.WriteFile(binary, []byte("new binary"), 0755)
+	_ = ioutil.WriteFile(path.Join(gopath, "bin", "linux_arm64_tool"), []byte("unrelated"), 0755)
+	_ = ioutil.WriteFile(e.buildStatePath(), []byte("new state"), 0644)
+
+	cloned := path.Join(gopath, "src", "github.com/acme/dep")
+	_ = os.MkdirAll(cloned, 0755)
+	snapshot.RecordCreated(cloned)
+
+	assert.Nil(t, snapshot.Restore(), "restore should succeed")
+
+	assert.Equal(t, first, gitIn(t, repoDir, "rev-parse", "HEAD"), "revision should be restored")
+	assert.Equal(t, "main", gitIn(t, repoDir, "symbolic-ref", "--short", "HEAD"), "branch should be checked out again")
+	assert.Equal(t, "old archive", readString(archive), "archive should be restored")
+	assert.Equal(t, "old binary", readString(binary), "binary should be restored")
+	assert.Equal(t, "old state", readString(e.buildStatePath()), "build state should be restored")
+
+	exists, _ := pathExists(cloned)
+	assert.False(t, exists, "clones made during the install should be removed")
+
+	exists, _ = pathExists(snapshot.BackupDir)
+	assert.False(t, exists, "saved artifacts should be cleaned up")
+}
+
+func TestRestoreRemovesArtifactsOfNewPackages(t *testing.T) {
+	e, cleanup := withTempGopath(t)
+	defer cleanup()
+
+	gopath := e.gopath
+	archive := path.Join(gopath, "pkg", "linux_amd64", "github.com/acme/new.a")
+
+	snapshot, err := e.snapshotVendor([]string{})
+	assert.Nil(t, err, "snapshot should be taken")
+
+	assert.Nil(t, snapshot.SaveArtifacts("github.com/acme/new"), "nothing to save should be fine")
+	_ = os.MkdirAll(path.Dir(archive), 0755)
+	_ = ioutil.WriteFile(archive, []byte("new archive"), 0644)
+	_ = ioutil.WriteFile(e.buildStatePath(), []byte("new state"), 0644)
+
+	assert.Nil(t, snapshot.Restore(), "restore should succeed")
+
+	exists, _ := pathExists(archive)
+	assert.False(t, exists, "archives of packages that weren't built before should be removed")
+
+	exists, _ = pathExists(e.buildStatePath())
+	assert.False(t, exists, "build state that didn't exist should be removed")
+}
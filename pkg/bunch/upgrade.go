@@ -0,0 +1,277 @@
+package bunch
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	version "github.com/hashicorp/go-version"
+	"github.com/juju/errors"
+)
+
+type UpgradeMode int
+
+const (
+	UpgradeInteractive UpgradeMode = iota
+	UpgradePatch
+	UpgradeMinor
+	UpgradeLatest
+)
+
+var maxChangelogLines = 20
+
+var singleConstraintRegexp = regexp.MustCompile(`^\s*(~>|>=|=)?\s*(v?)([0-9][0-9A-Za-z.\-+]*)\s*$`)
+
+type UpgradeCandidate struct {
+	Tag     string
+	Version *version.Version
+}
+
+// bumpConstraint rewrites a Bunchfile constraint so that it is satisfied by newTag,
+// keeping the original operator and precision where possible
+func bumpConstraint(constraint string, newTag string) string {
+	newVersion := strings.TrimPrefix(newTag, "v")
+
+	match := singleConstraintRegexp.FindStringSubmatch(constraint)
+	if match == nil {
+		return fmt.Sprintf(">= %s", newVersion)
+	}
+
+	operator, prefix, oldVersion := match[1], match[2], match[3]
+
+	if operator == "~>" {
+		precision := len(strings.Split(oldVersion, "."))
+		newParts := strings.Split(newVersion, ".")
+		if precision < len(newParts) {
+			newVersion = strings.Join(newParts[:precision], ".")
+		}
+	}
+
+	if operator == "" {
+		return prefix + newVersion
+	}
+
+	return fmt.Sprintf("%s %s%s", operator, prefix, newVersion)
+}
+
+// filterUpgradeCandidates returns the tags newer than current that fall outside constraints,
+// limited to the same major (minor mode) or major.minor (patch mode) as current
+func filterUpgradeCandidates(versions []*version.Version, versionToTag map[*version.Version]string, current *version.Version, constraints version.Constraints, mode UpgradeMode) []UpgradeCandidate {
+	candidates := []UpgradeCandidate{}
+
+	currentSegments := current.Segments()
+
+	for _, v := range versions {
+		if !v.GreaterThan(current) || (constraints != nil && constraints.Check(v)) {
+			continue
+		}
+
+		segments := v.Segments()
+
+		if mode == UpgradeMinor && segments[0] != currentSegments[0] {
+			continue
+		}
+
+		if mode == UpgradePatch && (segments[0] != currentSegments[0] || segments[1] != currentSegments[1]) {
+			continue
+		}
+
+		candidates = append(candidates, UpgradeCandidate{Tag: versionToTag[v], Version: v})
+	}
+
+	return candidates
+}
+
+func (e *engine) reportChangelog(changelog []string) { // one commit per line, at most maxChangelogLines of them
+	for i, line := range changelog {
+		if i >= maxChangelogLines {
+			e.reporter.Message(MessageInfo, fmt.Sprintf("    ... and %s more", CountCommits(len(changelog)-maxChangelogLines)))
+			break
+		}
+
+		e.reporter.Message(MessageInfo, "    "+line)
+	}
+}
+
+func (e *engine) reportUpgradeChangelog(repoDir string, fromRev string, toRev string) error {
+	changelog, err := e.getChangelog(repoDir, fromRev, toRev)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	e.reporter.Message(MessageInfo, fmt.Sprintf("  changes %s..%s:", ShortCommit(fromRev), toRev))
+
+	if len(changelog) == 0 {
+		e.reporter.Message(MessageInfo, "    (no commits)")
+	}
+
+	e.reportChangelog(changelog)
+
+	return nil
+}
+
+// UpgradeChooser picks the tag an interactive upgrade raises repo's constraint to, out of tags
+// (oldest first), or returns "" to leave it alone
+type UpgradeChooser func(repo string, tags []string) (string, error)
+
+func (e *engine) upgradePackage(pack Package, mode UpgradeMode, choose UpgradeChooser) (string, error) { // returns the new constraint, or "" if unchanged
+	constraints, err := version.NewConstraint(pack.Version)
+	if pack.Version == "" || err != nil {
+		if e.verbose {
+			e.reporter.Message(MessageWarning, fmt.Sprintf("package %s ... skipped, not constrained to a version range", pack.Repo))
+		}
+		return "", nil
+	}
+
+	err = e.fetchPackage(pack.Repo)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	packageDir, err := e.getPackageRootDir(getRealRepoPath(pack.Repo))
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	if exists, _ := pathExists(path.Join(packageDir, ".git")); !exists {
+		e.reporter.Message(MessageWarning, fmt.Sprintf("package %s ... skipped, only git packages can be upgraded", pack.Repo))
+		return "", nil
+	}
+
+	versions, versionToTag, err := e.getVersionTags(packageDir)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	wantedTag := matchingTag(versions, versionToTag, constraints)
+
+	var current *version.Version
+	for v, tag := range versionToTag {
+		if tag == wantedTag {
+			current = v
+		}
+	}
+
+	if current == nil {
+		e.reporter.Message(MessageWarning, fmt.Sprintf("package %s ... skipped, no tag matches %s", pack.Repo, pack.Version))
+		return "", nil
+	}
+
+	candidates := filterUpgradeCandidates(versions, versionToTag, current, constraints, mode)
+
+	if len(candidates) == 0 {
+		e.reporter.Message(MessageSuccess, fmt.Sprintf("package %s ... up to date", pack.Repo))
+		return "", nil
+	}
+
+	e.reporter.Message(MessageWarning, fmt.Sprintf("package %s %s (%s) ... %d newer version(s) available", pack.Repo, pack.Version, wantedTag, len(candidates)))
+
+	installedRevision, err := e.getInstalledRevision(pack.Repo)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	if installedRevision == "" {
+		installedRevision = wantedTag
+	}
+
+	var chosenTag string
+
+	if mode == UpgradeInteractive {
+		err = e.reportUpgradeChangelog(packageDir, installedRevision, candidates[len(candidates)-1].Tag)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+
+		tags := make([]string, len(candidates))
+		for i, candidate := range candidates {
+			tags[i] = candidate.Tag
+		}
+
+		chosenTag, err = choose(pack.Repo, tags)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+
+		if chosenTag == "" {
+			return "", nil
+		}
+	} else {
+		chosenTag = candidates[len(candidates)-1].Tag
+
+		err = e.reportUpgradeChangelog(packageDir, installedRevision, chosenTag)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+	}
+
+	newConstraint := bumpConstraint(pack.Version, chosenTag)
+
+	e.reporter.Message(MessageSuccess, fmt.Sprintf("  %s: %s -> %s", pack.Repo, pack.Version, newConstraint))
+
+	return newConstraint, nil
+}
+
+func (e *engine) upgradePackages(b *BunchFile, repos []string, opts UpgradeOptions) error {
+	if opts.Mode == UpgradeInteractive && opts.Choose == nil {
+		return errors.New("interactive upgrades need a way to choose a version")
+	}
+
+	err := e.useVendor()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	selected := make(map[string]bool)
+	for _, repo := range repos {
+		selected[parsePackage(repo, e.defaultHost).Repo] = true
+	}
+
+	for repo := range selected {
+		if _, present := b.PackageIndex(repo); !present {
+			return fmt.Errorf("package %s is not in Bunchfile", repo)
+		}
+	}
+
+	anyUpgraded := false
+
+	for _, pack := range b.Packages {
+		if pack.IsLink || (len(selected) > 0 && !selected[pack.Repo]) {
+			continue
+		}
+
+		if err := e.ctx.Err(); err != nil {
+			return errors.Trace(err)
+		}
+
+		newConstraint, err := e.upgradePackage(pack, opts.Mode, opts.Choose)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		if newConstraint == "" {
+			continue
+		}
+
+		err = b.AddPackage(fmt.Sprintf("%s@%s", pack.Repo, newConstraint))
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		anyUpgraded = true
+	}
+
+	if !anyUpgraded {
+		return nil
+	}
+
+	err = b.Save()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	e.reporter.Message(MessageSuccess, "Bunchfile updated, run 'bunch update' to install the new versions")
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package bunch
+
+import (
+	"testing"
+
+	version "github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBumpConstraint(t *testing.T) {
+	assert.Equal(t, "~> 2.0", bumpConstraint("~> 1.4", "v2.0.1"), "pessimistic constraint should keep its precision")
+	assert.Equal(t, "~> 1.5.2", bumpConstraint("~> 1.4.0", "v1.5.2"), "pessimistic constraint should keep its precision")
+	assert.Equal(t, ">= 2.0.1", bumpConstraint(">= 1.0", "v2.0.1"), "lower bound should be raised")
+	assert.Equal(t, "v2.0.1", bumpConstraint("v1.2.0", "v2.0.1"), "exact tag should be swapped")
+	assert.Equal(t, ">= 2.0.1", bumpConstraint("> 1.0, < 1.4", "v2.0.1"), "compound constraint should become a lower bound")
+}
+
+func TestFilterUpgradeCandidates(t *testing.T) {
+	versions, versionToTag := parseVersionTags([]string{"v1.2.0", "v1.2.3", "v1.3.0", "v2.0.1"})
+	current, _ := version.NewVersion("1.2.0")
+	constraints, _ := version.NewConstraint("= 1.2.0")
+
+	candidates := filterUpgradeCandidates(versions, versionToTag, current, constraints, UpgradeLatest)
+	assert.Equal(t, 3, len(candidates), "all newer versions should be candidates")
+	assert.Equal(t, "v2.0.1", candidates[2].Tag, "latest candidate should be last")
+
+	candidates = filterUpgradeCandidates(versions, versionToTag, current, constraints, UpgradeMinor)
+	assert.Equal(t, 2, len(candidates), "minor mode should exclude new major versions")
+	assert.Equal(t, "v1.3.0", candidates[1].Tag, "highest minor candidate should be last")
+
+	candidates = filterUpgradeCandidates(versions, versionToTag, current, constraints, UpgradePatch)
+	assert.Equal(t, 1, len(candidates), "patch mode should exclude new minor versions")
+	assert.Equal(t, "v1.2.3", candidates[0].Tag, "patch candidate should be v1.2.3")
+}
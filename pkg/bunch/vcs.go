@@ -0,0 +1,359 @@
+package bunch
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// RepoRoot describes where the code for an import path lives: Root is the import path
+// prefix that corresponds to the repository, checked out at .vendor/src/<Root>.
+type RepoRoot struct {
+	Root string
+	VCS  string
+	URL  string
+}
+
+type knownHost struct {
+	prefix  string
+	pattern *regexp.Regexp // must capture the root as the first group
+	vcs     string
+}
+
+var knownHosts = []knownHost{
+	{"github.com/", regexp.MustCompile(`^(github\.com/[A-Za-z0-9_.\-]+/[A-Za-z0-9_.\-]+)(/.*)?$`), "git"},
+	{"bitbucket.org/", regexp.MustCompile(`^(bitbucket\.org/[A-Za-z0-9_.\-]+/[A-Za-z0-9_.\-]+)(/.*)?$`), "git"},
+	{"launchpad.net/", regexp.MustCompile(`^(launchpad\.net/[A-Za-z0-9_.\-]+(/[A-Za-z0-9_.\-]+)?)(/.*)?$`), "bzr"},
+}
+
+// example.com/repo.git/sub names its repository and vcs explicitly
+var vcsSuffixRegexp = regexp.MustCompile(`^(([a-z0-9.\-]+\.[a-z]+(:[0-9]+)?/)?[A-Za-z0-9_.\-/~]+?)\.(git|hg|bzr|svn)(/.*)?$`)
+
+var vcsCloneCommands = map[string][]string{
+	"git": {"git", "clone", "-q", "{url}", "{dir}"},
+	"hg":  {"hg", "clone", "-q", "{url}", "{dir}"},
+	"bzr": {"bzr", "branch", "-q", "{url}", "{dir}"},
+	"svn": {"svn", "checkout", "-q", "{url}", "{dir}"},
+}
+
+var metaDiscoveryTimeout = 30 * time.Second
+
+var repoRootCache = struct {
+	sync.Mutex
+	roots map[string]RepoRoot
+}{roots: map[string]RepoRoot{}}
+
+func knownHostRepoRoot(importPath string) (RepoRoot, bool) {
+	for _, host := range knownHosts {
+		if !strings.HasPrefix(importPath, host.prefix) {
+			continue
+		}
+
+		match := host.pattern.FindStringSubmatch(importPath)
+		if match == nil {
+			continue
+		}
+
+		root := strings.TrimSuffix(match[1], ".git")
+		return RepoRoot{Root: root, VCS: host.vcs, URL: "https://" + root}, true
+	}
+
+	return RepoRoot{}, false
+}
+
+func vcsSuffixRepoRoot(importPath string) (RepoRoot, bool) {
+	match := vcsSuffixRegexp.FindStringSubmatch(importPath)
+	if match == nil {
+		return RepoRoot{}, false
+	}
+
+	root := match[1] + "." + match[4]
+	return RepoRoot{Root: root, VCS: match[4], URL: "https://" + root}, true
+}
+
+type metaImport struct {
+	Prefix string
+	VCS    string
+	URL    string
+}
+
+// parseMetaGoImports reads <meta name="go-import" content="prefix vcs url"> tags from the head
+// of an html page, the same way the go tool does
+func parseMetaGoImports(r io.Reader) ([]metaImport, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		switch strings.ToLower(charset) {
+		case "utf-8", "ascii", "us-ascii":
+			return input, nil
+		}
+		return nil, fmt.Errorf("can't decode XML document using charset %q", charset)
+	}
+
+	imports := []metaImport{}
+
+	for {
+		token, err := decoder.RawToken()
+		if err != nil {
+			if err == io.EOF || len(imports) > 0 {
+				break
+			}
+			return nil, errors.Trace(err)
+		}
+
+		if end, ok := token.(xml.EndElement); ok && strings.EqualFold(end.Name.Local, "head") {
+			break
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if strings.EqualFold(start.Name.Local, "body") {
+			break
+		}
+
+		if !strings.EqualFold(start.Name.Local, "meta") || xmlAttr(start, "name") != "go-import" {
+			continue
+		}
+
+		fields := strings.Fields(xmlAttr(start, "content"))
+		if len(fields) == 3 {
+			imports = append(imports, metaImport{Prefix: fields[0], VCS: fields[1], URL: fields[2]})
+		}
+	}
+
+	return imports, nil
+}
+
+func xmlAttr(start xml.StartElement, name string) string {
+	for _, attr := range start.Attr {
+		if strings.EqualFold(attr.Name.Local, name) {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func matchMetaImport(imports []metaImport, importPath string) (RepoRoot, error) {
+	var match *metaImport
+
+	for i, imp := range imports {
+		if imp.VCS == "mod" {
+			continue // module proxies can't be cloned
+		}
+
+		if importPath != imp.Prefix && !strings.HasPrefix(importPath, imp.Prefix+"/") {
+			continue
+		}
+
+		if match != nil {
+			return RepoRoot{}, fmt.Errorf("multiple go-import meta tags match %s", importPath)
+		}
+
+		match = &imports[i]
+	}
+
+	if match == nil {
+		return RepoRoot{}, fmt.Errorf("no go-import meta tag matches %s", importPath)
+	}
+
+	if _, known := vcsCloneCommands[match.VCS]; !known {
+		return RepoRoot{}, fmt.Errorf("unsupported vcs %q for %s", match.VCS, importPath)
+	}
+
+	return RepoRoot{Root: match.Prefix, VCS: match.VCS, URL: match.URL}, nil
+}
+
+// proxyFor picks the proxy for a request the way http.ProxyFromEnvironment does, but from env
+// rather than the process environment, which the config files' proxy settings aren't put into
+func proxyFor(env []string) func(*http.Request) (*url.URL, error) {
+	getenv := func(name string) string {
+		if value, ok := lookupEnv(env, name); ok {
+			return value
+		}
+
+		value, _ := lookupEnv(env, strings.ToLower(name))
+		return value
+	}
+
+	return func(request *http.Request) (*url.URL, error) {
+		proxy := getenv("HTTPS_PROXY")
+		if request.URL.Scheme == "http" {
+			proxy = getenv("HTTP_PROXY")
+		}
+
+		if proxy == "" {
+			return nil, nil
+		}
+
+		host := request.URL.Hostname()
+		for _, pattern := range strings.Split(getenv("NO_PROXY"), ",") {
+			pattern = strings.TrimPrefix(strings.TrimSpace(pattern), ".")
+			if pattern == "*" || (pattern != "" && (host == pattern || strings.HasSuffix(host, "."+pattern))) {
+				return nil, nil
+			}
+		}
+
+		if !strings.Contains(proxy, "://") {
+			proxy = "http://" + proxy
+		}
+
+		return url.Parse(proxy)
+	}
+}
+
+func (e *engine) discoverRepoRoot(importPath string) (RepoRoot, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFor(e.env)
+
+	client := &http.Client{Timeout: metaDiscoveryTimeout, Transport: transport}
+
+	imports, err := e.fetchMetaImports(client, importPath)
+	if err != nil {
+		return RepoRoot{}, errors.Trace(err)
+	}
+
+	root, err := matchMetaImport(imports, importPath)
+	if err != nil {
+		return RepoRoot{}, errors.Trace(err)
+	}
+
+	// the meta tag is only trusted for the prefix served at the root itself
+	if root.Root != importPath {
+		rootImports, err := e.fetchMetaImports(client, root.Root)
+		if err != nil {
+			return RepoRoot{}, errors.Trace(err)
+		}
+
+		verified, err := matchMetaImport(rootImports, root.Root)
+		if err != nil || verified != root {
+			return RepoRoot{}, fmt.Errorf("go-import meta tag for %s doesn't match the one served at %s", importPath, root.Root)
+		}
+	}
+
+	return root, nil
+}
+
+func (e *engine) fetchMetaImports(client *http.Client, importPath string) ([]metaImport, error) {
+	lookupURL := fmt.Sprintf("https://%s?go-get=1", importPath)
+
+	var imports []metaImport
+
+	err := e.withNetworkRetries("looking up "+lookupURL, func() error {
+		request, err := http.NewRequestWithContext(e.ctx, "GET", lookupURL, nil)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		resp, err := client.Do(request)
+		if err != nil {
+			return errors.Annotatef(err, "failed looking up repository for %s", importPath)
+		}
+		defer resp.Body.Close()
+
+		imports, err = parseMetaGoImports(resp.Body)
+		if len(imports) == 0 && resp.StatusCode >= 500 {
+			return &transientError{err: errors.Errorf("failed looking up %s: %s", lookupURL, resp.Status)}
+		} else if err != nil {
+			return errors.Annotatef(err, "failed parsing %s", lookupURL)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return imports, nil
+}
+
+// resolveRepoRoot works out which repository holds importPath: mirror rules, known hosts and
+// paths with an explicit .git/.hg/.bzr/.svn suffix are resolved locally, everything else via
+// go-import meta tags. Results are cached for the rest of the run.
+func (e *engine) resolveRepoRoot(importPath string) (RepoRoot, error) {
+	importPath = getRealRepoPath(importPath)
+
+	repoRootCache.Lock()
+	for root, repoRoot := range repoRootCache.roots {
+		if importPath == root || strings.HasPrefix(importPath, root+"/") {
+			repoRootCache.Unlock()
+			return repoRoot, nil
+		}
+	}
+	repoRootCache.Unlock()
+
+	repoRoot, ok := RepoRoot{}, false
+
+	if root, mirrorURL, mirrored := findMirror(e.mirrorRules, importPath); mirrored {
+		repoRoot, ok = RepoRoot{Root: root, VCS: "git", URL: mirrorURL}, true
+	}
+
+	if !ok {
+		repoRoot, ok = knownHostRepoRoot(importPath)
+	}
+
+	if !ok {
+		repoRoot, ok = vcsSuffixRepoRoot(importPath)
+	}
+
+	if !ok {
+		var err error
+		repoRoot, err = e.discoverRepoRoot(importPath)
+		if err != nil {
+			return RepoRoot{}, errors.Trace(err)
+		}
+	}
+
+	repoRootCache.Lock()
+	repoRootCache.roots[repoRoot.Root] = repoRoot
+	repoRootCache.Unlock()
+
+	return repoRoot, nil
+}
+
+func (e *engine) cloneRepo(repoRoot RepoRoot) error {
+	template, known := vcsCloneCommands[repoRoot.VCS]
+	if !known {
+		return fmt.Errorf("unsupported vcs %q for %s", repoRoot.VCS, repoRoot.Root)
+	}
+
+	gopath := e.gopath
+	targetDir := path.Join(gopath, "src", repoRoot.Root)
+
+	if err := os.MkdirAll(path.Dir(targetDir), 0755); err != nil {
+		return errors.Trace(err)
+	}
+
+	cloneCommand := make([]string, len(template))
+	for i, arg := range template {
+		cloneCommand[i] = strings.NewReplacer("{url}", repoRoot.URL, "{dir}", targetDir).Replace(arg)
+	}
+
+	_, err := e.runNetworkCommand(func() *exec.Cmd {
+		_ = os.RemoveAll(targetDir) // left over from a failed attempt
+		return e.command("", cloneCommand[0], cloneCommand[1:]...)
+	})
+	if err != nil {
+		_ = os.RemoveAll(targetDir)
+		return errors.Trace(err)
+	}
+
+	e.activeSnapshot.RecordCreated(targetDir) // removed again if the install rolls back
+
+	return nil
+}
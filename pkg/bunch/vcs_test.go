@@ -0,0 +1,56 @@
+package bunch
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKnownHostRepoRoot(t *testing.T) {
+	root, ok := knownHostRepoRoot("github.com/juju/errors/sub/pkg")
+	assert.True(t, ok, "github.com should be a known host")
+	assert.Equal(t, RepoRoot{Root: "github.com/juju/errors", VCS: "git", URL: "https://github.com/juju/errors"}, root, "root should stop at the repository")
+
+	_, ok = knownHostRepoRoot("golang.org/x/net")
+	assert.False(t, ok, "vanity paths need meta discovery")
+}
+
+func TestVCSSuffixRepoRoot(t *testing.T) {
+	root, ok := vcsSuffixRepoRoot("git.example.com/team/lib.git/sub")
+	assert.True(t, ok, "explicit suffix should be recognized")
+	assert.Equal(t, RepoRoot{Root: "git.example.com/team/lib.git", VCS: "git", URL: "https://git.example.com/team/lib.git"}, root, "root should end at the suffix")
+
+	root, ok = vcsSuffixRepoRoot("hg.example.com/lib.hg")
+	assert.True(t, ok, "hg suffix should be recognized")
+	assert.Equal(t, "hg", root.VCS, "vcs should come from the suffix")
+
+	_, ok = vcsSuffixRepoRoot("gopkg.in/yaml.v2")
+	assert.False(t, ok, "version suffixes aren't vcs suffixes")
+}
+
+func TestParseMetaGoImports(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html>
+<head>
+<meta http-equiv="Content-Type" content="text/html; charset=utf-8">
+<meta name="go-import" content="golang.org/x/net git https://go.googlesource.com/net">
+<meta name="go-import" content="golang.org/x/net mod https://proxy.golang.org">
+<meta name="go-source" content="golang.org/x/net https://github.com/golang/net/ https://github.com/golang/net/tree/master{/dir}">
+</head>
+<body>
+<meta name="go-import" content="golang.org/x/evil git https://evil.example.com/net">
+</body>
+</html>`
+
+	imports, err := parseMetaGoImports(strings.NewReader(page))
+	assert.Nil(t, err, "page should parse")
+	assert.Len(t, imports, 2, "only go-import tags in the head should be read")
+
+	root, err := matchMetaImport(imports, "golang.org/x/net/context")
+	assert.Nil(t, err, "subpackage should match the prefix")
+	assert.Equal(t, RepoRoot{Root: "golang.org/x/net", VCS: "git", URL: "https://go.googlesource.com/net"}, root, "mod entries should be skipped")
+
+	_, err = matchMetaImport(imports, "golang.org/x/network")
+	assert.NotNil(t, err, "prefixes should only match whole path elements")
+}
@@ -0,0 +1,328 @@
+package bunch
+
+import (
+	"fmt"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+
+	version "github.com/hashicorp/go-version"
+	"github.com/juju/errors"
+)
+
+func (e *engine) getLatestVersionMatchingPattern(repo string, versionPattern string, branch string) (string, error) {
+	repoPath, err := e.getPackageRootDir(repo)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	if exists, _ := pathExists(repoPath); !exists {
+		return versionPattern, nil
+	}
+
+	var repoType string
+
+	if exists, _ := pathExists(path.Join(repoPath, ".git")); exists {
+		repoType = "git"
+	} else if exists, _ := pathExists(path.Join(repoPath, ".hg")); exists {
+		repoType = "hg"
+	} else if exists, _ := pathExists(path.Join(repoPath, ".bzr")); exists {
+		repoType = "bzr"
+	} else {
+		return versionPattern, nil
+	}
+
+	if versionPattern == "" {
+		if repoType == "git" {
+			return e.getGitUpstreamRef(repoPath, branch)
+		} else if repoType == "hg" {
+			if branch != "" {
+				return branch, nil
+			}
+			return "tip", nil
+		} else if repoType == "bzr" {
+			return "", nil
+		}
+	}
+
+	if repoType == "hg" || repoType == "bzr" {
+		return versionPattern, nil
+	}
+
+	// first, try feeding it through git to see if it's a valid rev
+	gitResolveCommand := []string{"git", "rev-parse", "-q", "--verify", versionPattern}
+	output, err := e.commandOutput(e.command(repoPath, gitResolveCommand[0], gitResolveCommand[1:]...))
+
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return "", errors.Trace(err)
+		}
+	}
+
+	gitResolvedString := strings.TrimSpace(string(output))
+
+	if gitResolvedString != "" {
+		return gitResolvedString, nil
+	}
+
+	// second, try parsing it
+	versions, versionToTag, err := e.getVersionTags(repoPath)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	constraints, err := version.NewConstraint(versionPattern)
+	if err != nil {
+		return "", newError(KindConstraint, "version %s of package %s is neither a revision nor a version constraint", versionPattern, repo)
+	}
+
+	resultVersion := matchingTag(versions, versionToTag, constraints)
+
+	if resultVersion == "" {
+		return "", newError(KindConstraint, "unable to find a version matching constraint %s for package %s", versionPattern, repo)
+	}
+
+	gitResolveCommand = []string{"git", "rev-parse", "-q", "--verify", resultVersion}
+	output, err = e.commandOutput(e.command(repoPath, gitResolveCommand[0], gitResolveCommand[1:]...))
+
+	if err != nil {
+		return "", errors.Trace(err)
+	} else {
+		return strings.TrimSpace(string(output)), nil
+	}
+}
+
+func (e *engine) getGitRemote(repoDir string) (string, error) {
+	output, err := e.commandOutput(e.command(repoDir, "git", "remote"))
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	remotes := strings.Fields(string(output))
+
+	for _, remote := range remotes {
+		if remote == "origin" {
+			return remote, nil
+		}
+	}
+
+	if len(remotes) > 0 {
+		return remotes[0], nil
+	}
+
+	return "", nil
+}
+
+func (e *engine) getGitDefaultBranch(repoDir string, remote string) string {
+	if remote != "" {
+		// refs/remotes/<remote>/HEAD is set on clone and is used if present, without asking the remote; it
+		// may be missing for repos fetched some other way
+		output, err := e.commandOutput(e.command(repoDir, "git", "symbolic-ref", "-q", "--short", fmt.Sprintf("refs/remotes/%s/HEAD", remote)))
+		if err == nil && strings.TrimSpace(string(output)) != "" {
+			return strings.TrimPrefix(strings.TrimSpace(string(output)), remote+"/")
+		}
+
+		output, err = e.commandOutputWithin(e.command(repoDir, "git", "ls-remote", "--symref", remote, "HEAD"), e.networkTimeout)
+		if err == nil {
+			for _, line := range strings.Split(string(output), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) >= 2 && fields[0] == "ref:" && strings.HasPrefix(fields[1], "refs/heads/") {
+					branch := strings.TrimPrefix(fields[1], "refs/heads/")
+
+					// remember it so the next lookup doesn't need the network, unless the vendor tree
+					// mustn't change
+					if !e.dryRun && !e.readOnly {
+						_, _ = e.runCommand(e.command(repoDir, "git", "remote", "set-head", remote, branch))
+					}
+
+					return branch
+				}
+			}
+		}
+
+		for _, candidate := range []string{"main", "master"} {
+			if _, err := e.commandOutput(e.command(repoDir, "git", "rev-parse", "-q", "--verify", fmt.Sprintf("refs/remotes/%s/%s", remote, candidate))); err == nil {
+				return candidate
+			}
+		}
+	}
+
+	output, err := e.commandOutput(e.command(repoDir, "git", "symbolic-ref", "-q", "--short", "HEAD"))
+	if err == nil && strings.TrimSpace(string(output)) != "" {
+		return strings.TrimSpace(string(output))
+	}
+
+	return "master"
+}
+
+func (e *engine) getGitUpstreamRef(repoDir string, branch string) (string, error) { // returns e.g. origin/main
+	remote, err := e.getGitRemote(repoDir)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	if branch == "" {
+		branch = e.getGitDefaultBranch(repoDir, remote)
+	}
+
+	if remote == "" {
+		return branch, nil
+	}
+
+	return fmt.Sprintf("%s/%s", remote, branch), nil
+}
+
+func parseVersionTags(tagList []string) ([]*version.Version, map[*version.Version]string) {
+	versionToTag := make(map[*version.Version]string)
+	versions := []*version.Version{}
+
+	for _, tag := range tagList {
+		stringVersion := tag
+
+		if strings.HasPrefix(tag, "v") {
+			stringVersion = strings.Replace(tag, "v", "", 1)
+		}
+
+		v, err := version.NewVersion(stringVersion)
+		if err != nil {
+			continue
+		}
+
+		versions = append(versions, v)
+		versionToTag[v] = tag
+	}
+
+	sort.Sort(version.Collection(versions))
+
+	return versions, versionToTag
+}
+
+func (e *engine) getVersionTags(repoDir string) ([]*version.Version, map[*version.Version]string, error) {
+	tagListB, err := e.commandOutput(e.command(repoDir, "git", "tag"))
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	versions, versionToTag := parseVersionTags(strings.Split(strings.TrimSpace(string(tagListB)), "\n"))
+
+	return versions, versionToTag, nil
+}
+
+// matchingTag is the tag bunch picks for version constraints everywhere (install, outdated,
+// upgrade and resolving shared dependencies): the lowest one matching all of constraints
+func matchingTag(versions []*version.Version, versionToTag map[*version.Version]string, constraints ...version.Constraints) string {
+	for _, v := range versions {
+		matchesAll := true
+
+		for _, constraint := range constraints {
+			if !constraint.Check(v) {
+				matchesAll = false
+				break
+			}
+		}
+
+		if matchesAll {
+			return versionToTag[v]
+		}
+	}
+
+	return ""
+}
+
+func highestTag(versions []*version.Version, versionToTag map[*version.Version]string) string {
+	if len(versions) == 0 {
+		return ""
+	}
+
+	return versionToTag[versions[len(versions)-1]]
+}
+
+type PackageTagInfo struct {
+	CurrentTag string `json:"current_tag,omitempty"`
+	WantedTag  string `json:"wanted_tag,omitempty"`
+	LatestTag  string `json:"latest_tag,omitempty"`
+}
+
+// String summarizes the tags as "tags: current v1.0.0, wanted v1.2.0, latest v2.0.0", with - for
+// missing ones; it's empty for packages without version tags
+func (t PackageTagInfo) String() string {
+	if t.LatestTag == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("tags: current %s, wanted %s, latest %s", orDefault(t.CurrentTag, "-"), orDefault(t.WantedTag, "-"), t.LatestTag)
+}
+
+func (e *engine) getPackageTagInfo(repoDir string, versionPattern string) (PackageTagInfo, error) {
+	tagInfo := PackageTagInfo{}
+
+	versions, versionToTag, err := e.getVersionTags(repoDir)
+	if err != nil {
+		return tagInfo, errors.Trace(err)
+	}
+
+	tagInfo.LatestTag = highestTag(versions, versionToTag)
+
+	if versionPattern != "" {
+		if constraints, err := version.NewConstraint(versionPattern); err == nil {
+			tagInfo.WantedTag = matchingTag(versions, versionToTag, constraints)
+		}
+	}
+
+	headTagsB, err := e.commandOutput(e.command(repoDir, "git", "tag", "--points-at", "HEAD"))
+	if err != nil {
+		return tagInfo, errors.Trace(err)
+	}
+
+	headVersions, headVersionToTag := parseVersionTags(strings.Split(strings.TrimSpace(string(headTagsB)), "\n"))
+	tagInfo.CurrentTag = highestTag(headVersions, headVersionToTag)
+
+	return tagInfo, nil
+}
+
+func (e *engine) getChangelog(repoDir string, fromRev string, toRev string) ([]string, error) {
+	output, err := e.commandOutput(e.command(repoDir, "git", "log", "--pretty=format:%h %s", fmt.Sprintf("%s..%s", fromRev, toRev)))
+	if err != nil {
+		return nil, errors.Annotatef(err, "failed reading log between %s and %s", fromRev, toRev)
+	}
+
+	changelog := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if line != "" {
+			changelog = append(changelog, line)
+		}
+	}
+
+	return changelog, nil
+}
+
+func (e *engine) getInstalledRevision(repo string) (string, error) {
+	repoPath, err := e.getPackageRootDir(getRealRepoPath(repo))
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	return e.getRevisionAt(repoPath)
+}
+
+func (e *engine) getRevisionAt(repoPath string) (string, error) {
+	var revisionCommand []string
+
+	if exists, _ := pathExists(path.Join(repoPath, ".git")); exists {
+		revisionCommand = []string{"git", "rev-parse", "-q", "--verify", "HEAD"}
+	} else if exists, _ := pathExists(path.Join(repoPath, ".hg")); exists {
+		revisionCommand = []string{"hg", "identify", "-i"}
+	} else if exists, _ := pathExists(path.Join(repoPath, ".bzr")); exists {
+		revisionCommand = []string{"bzr", "revno"}
+	} else {
+		return "", nil
+	}
+
+	output, err := e.commandOutput(e.command(repoPath, revisionCommand[0], revisionCommand[1:]...))
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	return strings.TrimSuffix(strings.TrimSpace(string(output)), "+"), nil // hg marks dirty working copies with a trailing +
+}
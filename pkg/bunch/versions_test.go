@@ -0,0 +1,117 @@
+package bunch
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+
+	version "github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVersionTags(t *testing.T) {
+	versions, versionToTag := parseVersionTags([]string{"v1.2.0", "not-a-version", "1.10.0", "v1.9.3", ""})
+
+	assert.Equal(t, 3, len(versions), "unparseable tags should be skipped")
+	assert.Equal(t, "v1.2.0", versionToTag[versions[0]], "tags should be sorted ascending")
+	assert.Equal(t, "1.10.0", versionToTag[versions[2]], "tags should be sorted numerically")
+	assert.Equal(t, "1.10.0", highestTag(versions, versionToTag), "highest tag should be 1.10.0")
+}
+
+func TestMatchingTag(t *testing.T) {
+	versions, versionToTag := parseVersionTags([]string{"v1.2.0", "v1.2.3", "v1.3.0", "v2.0.1"})
+
+	constraints, err := version.NewConstraint("~> 1.2.0")
+	assert.Nil(t, err, "constraint should parse")
+	assert.Equal(t, "v1.2.0", matchingTag(versions, versionToTag, constraints), "should pick the lowest matching release")
+
+	constraints, err = version.NewConstraint(">= 3.0")
+	assert.Nil(t, err, "constraint should parse")
+	assert.Equal(t, "", matchingTag(versions, versionToTag, constraints), "no tag should match")
+
+	assert.Equal(t, "", highestTag(nil, nil), "no tags means no highest tag")
+}
+
+func gitIn(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, "git %v should succeed: %s", args, output)
+
+	return strings.TrimSpace(string(output))
+}
+
+// cloneTaggedRepo creates an upstream repository with a commit for each tag, changing VERSION, and
+// clones it into e's GOPATH as repo, which is mirrored from upstream; returns both directories
+func cloneTaggedRepo(t *testing.T, e *engine, repo string, tags ...string) (string, string) {
+	upstream := path.Join(e.gopath, "upstream", repo)
+	repoDir := path.Join(e.gopath, "src", repo)
+
+	_ = os.MkdirAll(upstream, 0755)
+	gitIn(t, upstream, "init", "-q")
+	for _, tag := range tags {
+		_ = ioutil.WriteFile(path.Join(upstream, "VERSION"), []byte(tag+"\n"), 0644)
+		gitIn(t, upstream, "add", "VERSION")
+		gitIn(t, upstream, "commit", "-q", "-m", "release "+tag)
+		gitIn(t, upstream, "tag", tag)
+	}
+
+	_ = os.MkdirAll(path.Dir(repoDir), 0755)
+	gitIn(t, upstream, "clone", "-q", upstream, repoDir)
+
+	e.mirrorRules = []MirrorRule{{Pattern: repo, URL: upstream}}
+
+	return upstream, repoDir
+}
+
+func TestGitDefaultBranchDryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bunch-versions")
+	assert.Nil(t, err, "temp dir should be created")
+	defer os.RemoveAll(dir)
+
+	upstream, clone := path.Join(dir, "upstream"), path.Join(dir, "clone")
+	_ = os.Mkdir(upstream, 0755)
+	gitIn(t, upstream, "init", "-q", "-b", "trunk")
+	gitIn(t, upstream, "commit", "-q", "--allow-empty", "-m", "first")
+	gitIn(t, dir, "clone", "-q", upstream, clone)
+	gitIn(t, clone, "remote", "set-head", "origin", "-d")
+
+	e := testEngine()
+	e.dryRun = true
+
+	assert.Equal(t, "trunk", e.getGitDefaultBranch(clone, "origin"), "default branch should be asked from the remote")
+
+	cmd := exec.Command("git", "symbolic-ref", "-q", "refs/remotes/origin/HEAD")
+	cmd.Dir = clone
+	_, err = cmd.Output()
+	assert.NotNil(t, err, "dry runs shouldn't record the remote's default branch")
+
+	e.dryRun = false
+	assert.Equal(t, "trunk", e.getGitDefaultBranch(clone, "origin"), "default branch should be asked from the remote")
+	assert.Equal(t, "refs/remotes/origin/trunk", gitIn(t, clone, "symbolic-ref", "-q", "refs/remotes/origin/HEAD"), "the remote's default branch should be recorded")
+}
+
+func TestInstallAndOutdatedAgreeOnWantedTag(t *testing.T) {
+	e, cleanup := withTempGopath(t)
+	defer cleanup()
+
+	repoDir := path.Join(e.gopath, "src", "example.com", "lib")
+	_ = os.MkdirAll(repoDir, 0755)
+	gitIn(t, repoDir, "init", "-q")
+	for _, tag := range []string{"v1.0.0", "v1.4.2", "v2.0.0"} {
+		gitIn(t, repoDir, "commit", "-q", "--allow-empty", "-m", tag)
+		gitIn(t, repoDir, "tag", tag)
+	}
+
+	installed, err := e.getLatestVersionMatchingPattern("example.com/lib", "~> 1.0", "")
+	assert.Nil(t, err, "constraint should resolve")
+
+	tagInfo, err := e.getPackageTagInfo(repoDir, "~> 1.0")
+	assert.Nil(t, err, "tag info should be read")
+	assert.Equal(t, installed, gitIn(t, repoDir, "rev-parse", tagInfo.WantedTag+"^{commit}"), "outdated should want the tag install checks out")
+	assert.Equal(t, "v2.0.0", tagInfo.LatestTag, "latest tag should be the highest overall")
+}